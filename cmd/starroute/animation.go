@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"path"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// spriteSheet holds the frames of a sprite sheet and its animation clips.
+type spriteSheet struct {
+	frames        []animFrame
+	width, height int // frame size, the largest one
+	clips         map[string]*animClip
+	first         string // the first clip of the sheet
+}
+
+// animFrame is a frame of a sprite sheet.
+type animFrame struct {
+	image            *ebiten.Image // sub-image of the sheet image
+	offsetX, offsetY int           // of image within the frame, when trimmed
+	duration         float64       // seconds
+}
+
+// animClip is a named animation, played in one of three modes: looping
+// (repeat 0), once or a number of times (repeat n), and ping-pong, going
+// back and forth, either forever or a number of times.
+type animClip struct {
+	name     string
+	frames   []int // sheet frame indices, in play order
+	pingPong bool
+	repeat   int              // times played, 0 for forever
+	events   map[int][]string // by sheet frame index
+}
+
+// sheetAllClip is the clip of a sheet without tags, playing all frames.
+const sheetAllClip = "all"
+
+// newSpriteSheet builds the sprite sheet described by a from the sheet
+// image. Each tag becomes a clip.
+func newSpriteSheet(a *asepriteSheet, img *ebiten.Image) *spriteSheet {
+	sheet := &spriteSheet{clips: map[string]*animClip{}}
+	for _, f := range a.frames {
+		var sub *ebiten.Image
+		if img != nil {
+			sub = img.SubImage(f.rect).(*ebiten.Image)
+		}
+		sheet.frames = append(sheet.frames, animFrame{
+			image:    sub,
+			offsetX:  f.offsetX,
+			offsetY:  f.offsetY,
+			duration: f.duration.Seconds(),
+		})
+		sheet.width = max(sheet.width, f.width)
+		sheet.height = max(sheet.height, f.height)
+	}
+
+	tags := a.tags
+	if len(tags) == 0 {
+		tags = []asepriteTag{{name: sheetAllClip, to: len(a.frames) - 1, direction: "forward"}}
+	}
+	for _, t := range tags {
+		clip := &animClip{
+			name:     t.name,
+			pingPong: t.direction == "pingpong" || t.direction == "pingpong_reverse",
+			repeat:   t.repeat,
+			events:   t.events,
+		}
+		for i := t.from; i <= t.to; i++ {
+			clip.frames = append(clip.frames, i)
+		}
+		if t.direction == "reverse" || t.direction == "pingpong_reverse" {
+			for i, j := 0, len(clip.frames)-1; i < j; i, j = i+1, j-1 {
+				clip.frames[i], clip.frames[j] = clip.frames[j], clip.frames[i]
+			}
+		}
+		if _, found := sheet.clips[t.name]; !found {
+			sheet.clips[t.name] = clip
+		}
+		if sheet.first == "" {
+			sheet.first = t.name
+		}
+	}
+	return sheet
+}
+
+// loadSpriteSheet loads a sprite sheet from an Aseprite JSON data file in
+// assets, along with its image.
+func loadSpriteSheet(filename string) (*spriteSheet, error) {
+	data, err := loadAsset(filename)
+	if err != nil {
+		return nil, err
+	}
+	a, err := parseAseprite(data, path.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	data, err = loadAsset(a.image)
+	if err != nil {
+		return nil, err
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("sprite sheet image %s: %w", a.image, err)
+	}
+	return newSpriteSheet(a, ebiten.NewImageFromImage(decoded)), nil
+}
+
+// animation plays the clips of a sprite sheet.
+type animation struct {
+	sheet   *spriteSheet
+	clip    *animClip
+	pos     int     // position in the clip frames
+	step    int     // 1 or -1, the ping-pong direction
+	elapsed float64 // seconds into the current frame
+	cycles  int     // times the clip was played through
+	done    bool    // the clip was played its number of times
+
+	// onEvent is called with the events of each frame shown, and with
+	// "end" when the clip is done.
+	onEvent func(clip, event string)
+}
+
+// newAnimation returns an animation playing the clip of sheet, or its
+// first clip if clip is empty.
+func newAnimation(sheet *spriteSheet, clip string) (*animation, error) {
+	a := &animation{sheet: sheet}
+	if clip == "" {
+		clip = sheet.first
+	}
+	if !a.play(clip) {
+		return nil, fmt.Errorf("unknown animation clip: %q", clip)
+	}
+	return a, nil
+}
+
+// play starts the clip name from its first frame, unless it is already
+// playing. It reports whether the sheet has the clip.
+func (a *animation) play(name string) bool {
+	clip := a.sheet.clips[name]
+	if clip == nil {
+		return false
+	}
+	if clip == a.clip && !a.done {
+		return true
+	}
+	a.clip = clip
+	a.pos, a.step, a.elapsed, a.cycles, a.done = 0, 1, 0, 0, false
+	a.show()
+	return true
+}
+
+// looping reports whether the clip plays forever.
+func (a *animation) looping() bool {
+	return a.clip.repeat == 0
+}
+
+// frame returns the sheet frame shown.
+func (a *animation) frame() animFrame {
+	return a.sheet.frames[a.clip.frames[a.pos]]
+}
+
+// update advances the animation by dt seconds, going through as many
+// frames as their durations take.
+func (a *animation) update(dt float64) {
+	if a.done {
+		return
+	}
+	a.elapsed += dt
+	for !a.done && a.elapsed >= a.frame().duration {
+		a.elapsed -= a.frame().duration
+		a.advance()
+	}
+}
+
+// advance shows the next frame of the clip, or ends the clip if it was
+// played its number of times.
+func (a *animation) advance() {
+	clip := a.clip
+	last := len(clip.frames) - 1
+
+	if clip.pingPong && last > 0 {
+		if a.pos+a.step < 0 || a.pos+a.step > last {
+			a.step = -a.step
+		}
+		a.pos += a.step
+		a.show()
+		if a.pos == 0 {
+			// back to the start
+			a.endCycle()
+		}
+		return
+	}
+
+	if a.pos < last {
+		a.pos++
+		a.show()
+		return
+	}
+	a.endCycle()
+	if !a.done {
+		a.pos = 0
+		a.show()
+	}
+}
+
+// endCycle counts a play through the clip, ending it after its number of
+// times. The frame shown is kept.
+func (a *animation) endCycle() {
+	a.cycles++
+	if a.clip.repeat > 0 && a.cycles >= a.clip.repeat {
+		a.done = true
+		a.emit("end")
+	}
+}
+
+// show fires the events of the frame shown.
+func (a *animation) show() {
+	for _, event := range a.clip.events[a.clip.frames[a.pos]] {
+		a.emit(event)
+	}
+}
+
+func (a *animation) emit(event string) {
+	if a.onEvent != nil {
+		a.onEvent(a.clip.name, event)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type animationTest struct {
+	name         string
+	tag          asepriteTag // of a sheet of 4 frames of 1 second
+	dt           float64
+	steps        int
+	expectFrames []int // sheet frame shown at the start and after each step
+	expectEvents string
+	expectDone   bool
+}
+
+var animationTestTable = []animationTest{
+	{"loop", asepriteTag{to: 2, direction: "forward"}, 1, 4, []int{0, 1, 2, 0, 1}, "", false},
+	{"loop range", asepriteTag{from: 1, to: 3, direction: "forward"}, 1, 3, []int{1, 2, 3, 1}, "", false},
+	{"reverse", asepriteTag{to: 2, direction: "reverse"}, 1, 3, []int{2, 1, 0, 2}, "", false},
+	{"once", asepriteTag{to: 2, direction: "forward", repeat: 1}, 1, 4, []int{0, 1, 2, 2, 2}, "end", true},
+	{"twice", asepriteTag{to: 1, direction: "forward", repeat: 2}, 1, 4, []int{0, 1, 0, 1, 1}, "end", true},
+	{"ping-pong", asepriteTag{to: 2, direction: "pingpong"}, 1, 6, []int{0, 1, 2, 1, 0, 1, 2}, "", false},
+	{"ping-pong reverse", asepriteTag{to: 2, direction: "pingpong_reverse"}, 1, 4, []int{2, 1, 0, 1, 2}, "", false},
+	{"ping-pong once", asepriteTag{to: 2, direction: "pingpong", repeat: 1}, 1, 5, []int{0, 1, 2, 1, 0, 0}, "end", true},
+	{"single frame ping-pong", asepriteTag{from: 3, to: 3, direction: "pingpong"}, 1, 2, []int{3, 3, 3}, "", false},
+	{"short ticks", asepriteTag{to: 1, direction: "forward"}, 0.25, 5, []int{0, 0, 0, 0, 1, 1}, "", false},
+	{"long tick skips frames", asepriteTag{to: 3, direction: "forward"}, 2.5, 1, []int{0, 2}, "", false},
+	{"events", asepriteTag{to: 2, direction: "forward", repeat: 1, events: map[int][]string{0: {"start"}, 2: {"blast", "debris"}}},
+		1, 3, []int{0, 1, 2, 2}, "start blast debris end", true},
+}
+
+// go test -count 1 -run '^TestAnimation$' ./...
+func TestAnimation(t *testing.T) {
+	for i, data := range animationTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(animationTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			a := &asepriteSheet{}
+			for range 4 {
+				a.frames = append(a.frames, asepriteFrame{width: 8, height: 8, duration: time.Second})
+			}
+			data.tag.name = "clip"
+			a.tags = []asepriteTag{data.tag}
+			sheet := newSpriteSheet(a, nil)
+
+			var events []string
+			anim := &animation{sheet: sheet, onEvent: func(clip, event string) {
+				events = append(events, event)
+			}}
+			if !anim.play("clip") {
+				t.Fatalf("clip not found")
+			}
+
+			frames := []int{anim.clip.frames[anim.pos]}
+			for range data.steps {
+				anim.update(data.dt)
+				frames = append(frames, anim.clip.frames[anim.pos])
+			}
+			if !reflect.DeepEqual(frames, data.expectFrames) {
+				t.Errorf("expected frames %v, got %v", data.expectFrames, frames)
+			}
+			if got := strings.Join(events, " "); got != data.expectEvents {
+				t.Errorf("expected events %q, got %q", data.expectEvents, got)
+			}
+			if anim.done != data.expectDone {
+				t.Errorf("expected done=%t", data.expectDone)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestAnimationPlay$' ./...
+func TestAnimationPlay(t *testing.T) {
+	a := &asepriteSheet{tags: []asepriteTag{
+		{name: "idle", to: 1, direction: "forward"},
+		{name: "explode", from: 2, to: 3, direction: "forward", repeat: 1},
+	}}
+	for range 4 {
+		a.frames = append(a.frames, asepriteFrame{width: 8, height: 16, duration: time.Second})
+	}
+	sheet := newSpriteSheet(a, nil)
+	if sheet.width != 8 || sheet.height != 16 {
+		t.Errorf("expected frame size 8x16, got %dx%d", sheet.width, sheet.height)
+	}
+
+	anim, err := newAnimation(sheet, "")
+	if err != nil || anim.clip.name != "idle" {
+		t.Fatalf("expected first clip idle: %v", err)
+	}
+	if _, err := newAnimation(sheet, "run"); err == nil {
+		t.Errorf("expected error for unknown clip")
+	}
+
+	// playing the clip playing does not restart it
+	anim.update(1)
+	anim.play("idle")
+	if anim.pos != 1 {
+		t.Errorf("expected idle kept playing at frame 1, got %d", anim.pos)
+	}
+
+	// steering does not cut an explosion short
+	spr := &sprite{anim: anim}
+	anim.play("explode")
+	spr.animateSteering(true)
+	if anim.clip.name != "explode" {
+		t.Errorf("expected explosion kept playing, got %s", anim.clip.name)
+	}
+	anim.update(2)
+	spr.animateSteering(false)
+	if anim.clip.name != "idle" {
+		t.Errorf("expected idle after the explosion, got %s", anim.clip.name)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// asepriteSheet is the subset of an Aseprite sprite sheet used by the
+// game, from the JSON data file exported along the sheet image, with the
+// frames either as an array or as a hash.
+// See https://www.aseprite.org/docs/cli/#data
+type asepriteSheet struct {
+	image  string // asset path, already resolved relative to the data file
+	frames []asepriteFrame
+	tags   []asepriteTag
+}
+
+// asepriteFrame is a frame of the sheet.
+type asepriteFrame struct {
+	rect             image.Rectangle // within the sheet image
+	offsetX, offsetY int             // of rect within the frame, when trimmed
+	width, height    int             // size of the frame, before trimming
+	duration         time.Duration
+}
+
+// asepriteTag is a named range of frames, played as an animation clip.
+type asepriteTag struct {
+	name      string
+	from, to  int    // frame indices, inclusive
+	direction string // forward, reverse, pingpong or pingpong_reverse
+	repeat    int    // times played, 0 for forever
+
+	// events are named in the tag user data as "frame:event" pairs,
+	// like "8:blast 11:debris". The key is the frame index.
+	events map[int][]string
+}
+
+type asepriteJSON struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image     string            `json:"image"`
+		FrameTags []asepriteJSONTag `json:"frameTags"`
+	} `json:"meta"`
+}
+
+type asepriteJSONRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type asepriteJSONFrame struct {
+	Frame            asepriteJSONRect `json:"frame"`
+	Rotated          bool             `json:"rotated"`
+	SpriteSourceSize asepriteJSONRect `json:"spriteSourceSize"`
+	SourceSize       struct {
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"sourceSize"`
+	Duration int `json:"duration"` // milliseconds
+}
+
+type asepriteJSONTag struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Direction string `json:"direction"`
+	Repeat    string `json:"repeat"`
+	Data      string `json:"data"`
+}
+
+// parseAseprite decodes an Aseprite JSON data file. dir is the directory
+// of the file within assets, where the sheet image is found.
+func parseAseprite(data []byte, dir string) (*asepriteSheet, error) {
+	var doc asepriteJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Meta.Image == "" {
+		return nil, errors.New("aseprite: missing meta image")
+	}
+	frames, err := decodeAsepriteFrames(doc.Frames)
+	if err != nil {
+		return nil, fmt.Errorf("aseprite: frames: %w", err)
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("aseprite: no frames")
+	}
+
+	sheet := &asepriteSheet{image: path.Join(dir, doc.Meta.Image)}
+
+	for i, f := range frames {
+		if f.Rotated {
+			return nil, fmt.Errorf("aseprite: frame %d: rotated frames are not supported", i)
+		}
+		if f.Frame.W <= 0 || f.Frame.H <= 0 {
+			return nil, fmt.Errorf("aseprite: frame %d: bad size %dx%d", i, f.Frame.W, f.Frame.H)
+		}
+		if f.Duration <= 0 {
+			return nil, fmt.Errorf("aseprite: frame %d: bad duration %d", i, f.Duration)
+		}
+		width, height := f.SourceSize.W, f.SourceSize.H
+		if width == 0 || height == 0 {
+			// not trimmed
+			width, height = f.Frame.W, f.Frame.H
+		}
+		sheet.frames = append(sheet.frames, asepriteFrame{
+			rect:     image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H),
+			offsetX:  f.SpriteSourceSize.X,
+			offsetY:  f.SpriteSourceSize.Y,
+			width:    width,
+			height:   height,
+			duration: time.Duration(f.Duration) * time.Millisecond,
+		})
+	}
+
+	for _, t := range doc.Meta.FrameTags {
+		tag, err := parseAsepriteTag(t, len(sheet.frames))
+		if err != nil {
+			return nil, fmt.Errorf("aseprite: tag %q: %w", t.Name, err)
+		}
+		sheet.tags = append(sheet.tags, tag)
+	}
+
+	return sheet, nil
+}
+
+// decodeAsepriteFrames decodes the frames as exported either as an array
+// or as a hash keyed by frame file name. The hash is decoded in file
+// order, which is the frame order.
+func decodeAsepriteFrames(raw json.RawMessage) ([]asepriteJSONFrame, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var frames []asepriteJSONFrame
+		err := json.Unmarshal(raw, &frames)
+		return frames, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected array or hash, got %v", tok)
+	}
+	var frames []asepriteJSONFrame
+	for dec.More() {
+		if _, err := dec.Token(); err != nil { // frame file name
+			return nil, err
+		}
+		var f asepriteJSONFrame
+		if err := dec.Decode(&f); err != nil {
+			return nil, err
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// parseAsepriteTag checks a tag of a sheet with frameCount frames.
+func parseAsepriteTag(t asepriteJSONTag, frameCount int) (asepriteTag, error) {
+	tag := asepriteTag{
+		name:      t.Name,
+		from:      t.From,
+		to:        t.To,
+		direction: t.Direction,
+	}
+	if tag.name == "" {
+		return tag, errors.New("missing name")
+	}
+	if tag.from < 0 || tag.to >= frameCount || tag.from > tag.to {
+		return tag, fmt.Errorf("bad frame range %d..%d of %d frames", tag.from, tag.to, frameCount)
+	}
+	switch tag.direction {
+	case "":
+		tag.direction = "forward"
+	case "forward", "reverse", "pingpong", "pingpong_reverse":
+	default:
+		return tag, fmt.Errorf("unknown direction: %q", tag.direction)
+	}
+	if t.Repeat != "" {
+		n, err := strconv.Atoi(t.Repeat)
+		if err != nil || n < 0 {
+			return tag, fmt.Errorf("bad repeat: %q", t.Repeat)
+		}
+		tag.repeat = n
+	}
+	for _, field := range strings.Fields(t.Data) {
+		frame, event, found := strings.Cut(field, ":")
+		n, err := strconv.Atoi(frame)
+		if !found || err != nil || event == "" {
+			return tag, fmt.Errorf("bad event %q, expected frame:event", field)
+		}
+		if n < tag.from || n > tag.to {
+			return tag, fmt.Errorf("event %q: frame out of the tag", field)
+		}
+		if tag.events == nil {
+			tag.events = map[int][]string{}
+		}
+		tag.events[n] = append(tag.events[n], event)
+	}
+	return tag, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type asepriteTest struct {
+	name         string
+	data         string
+	expectError  bool
+	expectImage  string
+	expectFrames []asepriteFrame
+	expectTags   []asepriteTag
+}
+
+var asepriteTestTable = []asepriteTest{
+	{
+		name: "array",
+		data: `{"frames": [
+			{"frame": {"x": 0, "y": 0, "w": 16, "h": 8}, "duration": 100},
+			{"frame": {"x": 16, "y": 0, "w": 16, "h": 8}, "duration": 50}],
+			"meta": {"image": "ship.png"}}`,
+		expectImage: "sprites/ship.png",
+		expectFrames: []asepriteFrame{
+			{rect: image.Rect(0, 0, 16, 8), width: 16, height: 8, duration: 100 * time.Millisecond},
+			{rect: image.Rect(16, 0, 32, 8), width: 16, height: 8, duration: 50 * time.Millisecond},
+		},
+	},
+	{
+		name: "hash in file order, trimmed",
+		data: `{"frames": {
+			"b 1": {"frame": {"x": 10, "y": 0, "w": 4, "h": 6}, "spriteSourceSize": {"x": 2, "y": 1, "w": 4, "h": 6}, "sourceSize": {"w": 8, "h": 8}, "duration": 100},
+			"a 0": {"frame": {"x": 0, "y": 0, "w": 8, "h": 8}, "duration": 100}},
+			"meta": {"image": "ship.png"}}`,
+		expectImage: "sprites/ship.png",
+		expectFrames: []asepriteFrame{
+			{rect: image.Rect(10, 0, 14, 6), offsetX: 2, offsetY: 1, width: 8, height: 8, duration: 100 * time.Millisecond},
+			{rect: image.Rect(0, 0, 8, 8), width: 8, height: 8, duration: 100 * time.Millisecond},
+		},
+	},
+	{
+		name: "tags",
+		data: `{"frames": [
+			{"frame": {"x": 0, "y": 0, "w": 8, "h": 8}, "duration": 100},
+			{"frame": {"x": 8, "y": 0, "w": 8, "h": 8}, "duration": 100},
+			{"frame": {"x": 16, "y": 0, "w": 8, "h": 8}, "duration": 100}],
+			"meta": {"image": "ship.png", "frameTags": [
+				{"name": "idle", "from": 0, "to": 0},
+				{"name": "boom", "from": 1, "to": 2, "direction": "pingpong", "repeat": "2", "data": "2:blast 2:debris"}]}}`,
+		expectImage: "sprites/ship.png",
+		expectFrames: []asepriteFrame{
+			{rect: image.Rect(0, 0, 8, 8), width: 8, height: 8, duration: 100 * time.Millisecond},
+			{rect: image.Rect(8, 0, 16, 8), width: 8, height: 8, duration: 100 * time.Millisecond},
+			{rect: image.Rect(16, 0, 24, 8), width: 8, height: 8, duration: 100 * time.Millisecond},
+		},
+		expectTags: []asepriteTag{
+			{name: "idle", direction: "forward"},
+			{name: "boom", from: 1, to: 2, direction: "pingpong", repeat: 2,
+				events: map[int][]string{2: {"blast", "debris"}}},
+		},
+	},
+	{
+		name:        "bad json",
+		data:        `{"frames": [`,
+		expectError: true,
+	},
+	{
+		name:        "missing image",
+		data:        `{"frames": [{"frame": {"w": 8, "h": 8}, "duration": 100}], "meta": {}}`,
+		expectError: true,
+	},
+	{
+		name:        "no frames",
+		data:        `{"frames": [], "meta": {"image": "ship.png"}}`,
+		expectError: true,
+	},
+	{
+		name:        "frames not a list",
+		data:        `{"frames": 3, "meta": {"image": "ship.png"}}`,
+		expectError: true,
+	},
+	{
+		name:        "zero duration",
+		data:        `{"frames": [{"frame": {"w": 8, "h": 8}}], "meta": {"image": "ship.png"}}`,
+		expectError: true,
+	},
+	{
+		name:        "rotated",
+		data:        `{"frames": [{"frame": {"w": 8, "h": 8}, "rotated": true, "duration": 100}], "meta": {"image": "ship.png"}}`,
+		expectError: true,
+	},
+	{
+		name: "tag out of range",
+		data: `{"frames": [{"frame": {"w": 8, "h": 8}, "duration": 100}],
+			"meta": {"image": "ship.png", "frameTags": [{"name": "run", "from": 0, "to": 1}]}}`,
+		expectError: true,
+	},
+	{
+		name: "unknown direction",
+		data: `{"frames": [{"frame": {"w": 8, "h": 8}, "duration": 100}],
+			"meta": {"image": "ship.png", "frameTags": [{"name": "run", "direction": "sideways"}]}}`,
+		expectError: true,
+	},
+	{
+		name: "bad repeat",
+		data: `{"frames": [{"frame": {"w": 8, "h": 8}, "duration": 100}],
+			"meta": {"image": "ship.png", "frameTags": [{"name": "run", "repeat": "-1"}]}}`,
+		expectError: true,
+	},
+	{
+		name: "bad event",
+		data: `{"frames": [{"frame": {"w": 8, "h": 8}, "duration": 100}],
+			"meta": {"image": "ship.png", "frameTags": [{"name": "run", "data": "blast"}]}}`,
+		expectError: true,
+	},
+	{
+		name: "event out of tag",
+		data: `{"frames": [{"frame": {"w": 8, "h": 8}, "duration": 100}],
+			"meta": {"image": "ship.png", "frameTags": [{"name": "run", "data": "1:blast"}]}}`,
+		expectError: true,
+	},
+}
+
+// go test -count 1 -run '^TestParseAseprite$' ./...
+func TestParseAseprite(t *testing.T) {
+	for i, data := range asepriteTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(asepriteTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			sheet, err := parseAseprite([]byte(data.data), "sprites")
+			if data.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %+v", sheet)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sheet.image != data.expectImage {
+				t.Errorf("expected image %q, got %q", data.expectImage, sheet.image)
+			}
+			if !reflect.DeepEqual(sheet.frames, data.expectFrames) {
+				t.Errorf("expected frames %+v, got %+v", data.expectFrames, sheet.frames)
+			}
+			if !reflect.DeepEqual(sheet.tags, data.expectTags) {
+				t.Errorf("expected tags %+v, got %+v", data.expectTags, sheet.tags)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestAsepriteAsset$' ./...
+func TestAsepriteAsset(t *testing.T) {
+	data, err := os.ReadFile("../../assets/ship_01.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := parseAseprite(data, ".")
+	if err != nil {
+		t.Fatalf("ship sheet: %v", err)
+	}
+	sheet := newSpriteSheet(a, nil)
+	for _, clip := range []string{"idle", "thrust", "explode"} {
+		if sheet.clips[clip] == nil {
+			t.Errorf("ship sheet: missing clip %q", clip)
+		}
+	}
+}
@@ -17,3 +17,8 @@ func mustLoadAsset(caller, filename string) []byte {
 	}
 	return data
 }
+
+func saveAsset(filename string, data []byte) error {
+	output := "assets/" + filename
+	return os.WriteFile(output, data, 0o644)
+}
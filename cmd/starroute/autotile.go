@@ -0,0 +1,239 @@
+package main
+
+import "github.com/udhos/starroute/torus"
+
+// autotileKind selects how the neighbors of a cell form its mask.
+type autotileKind int
+
+const (
+	// autotileWang16 looks at the 4 edge neighbors: 16 masks.
+	autotileWang16 autotileKind = iota
+
+	// autotileBlob47 looks at the 8 neighbors, counting a corner only
+	// when both edges next to it are terrain too: 47 distinct masks.
+	autotileBlob47
+)
+
+// Neighbor bits of an autotile mask.
+// Wang16 masks use only the edge bits, packed as N=1 E=2 S=4 W=8.
+const (
+	autotileN  = 1
+	autotileNE = 2
+	autotileE  = 4
+	autotileSE = 8
+	autotileS  = 16
+	autotileSW = 32
+	autotileW  = 64
+	autotileNW = 128
+)
+
+// autotileNeighbors lists the neighbor offsets in blob mask bit order.
+var autotileNeighbors = [8][2]int{
+	{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1},
+}
+
+// autotileCorners lists each corner bit with the bits of its two edges.
+var autotileCorners = [4][3]int{
+	{autotileNE, autotileN, autotileE},
+	{autotileSE, autotileS, autotileE},
+	{autotileSW, autotileS, autotileW},
+	{autotileNW, autotileN, autotileW},
+}
+
+// autotileRules picks the tile for each terrain cell from the mask of
+// its neighbors, so region borders get proper edge and corner tiles.
+type autotileRules struct {
+	kind       autotileKind
+	tiles      map[int]int  // mask => global tile ID
+	background int          // global tile ID of cells outside the terrain
+	fallback   int          // global tile ID of terrain cells with a mask missing from tiles
+	variants   map[int]bool // alternative tiles for cells fully inside the terrain
+	members    map[int]bool // global tile IDs that belong to the terrain
+}
+
+// newAutotileRules creates rules mapping masks to global tile IDs.
+// Variants are kept as they are where the cell is fully inside the terrain,
+// so decorated tiles survive when neighbors are autotiled again.
+func newAutotileRules(kind autotileKind, tiles map[int]int, background, fallback int,
+	variants ...int) *autotileRules {
+	r := &autotileRules{
+		kind:       kind,
+		tiles:      tiles,
+		background: background,
+		fallback:   fallback,
+		variants:   map[int]bool{},
+		members:    map[int]bool{fallback: true},
+	}
+	for _, gid := range tiles {
+		r.members[gid] = true
+	}
+	for _, gid := range variants {
+		r.variants[gid] = true
+		r.members[gid] = true
+	}
+	return r
+}
+
+// member reports whether the global tile ID belongs to the terrain.
+func (r *autotileRules) member(gid int) bool {
+	return r.members[gid]
+}
+
+// fullMask is the mask of a cell fully inside the terrain.
+func (r *autotileRules) fullMask() int {
+	if r.kind == autotileWang16 {
+		return 15
+	}
+	return 255
+}
+
+// mask computes the mask of a cell. inTerrain reports whether the
+// neighbor at offset dx,dy belongs to the terrain.
+func (r *autotileRules) mask(inTerrain func(dx, dy int) bool) int {
+	var blob int
+	for bit, n := range autotileNeighbors {
+		if inTerrain(n[0], n[1]) {
+			blob |= 1 << bit
+		}
+	}
+
+	if r.kind == autotileWang16 {
+		var m int
+		for i, bit := range []int{autotileN, autotileE, autotileS, autotileW} {
+			if blob&bit != 0 {
+				m |= 1 << i
+			}
+		}
+		return m
+	}
+
+	// a corner only counts if both of its edges are terrain
+	for _, c := range autotileCorners {
+		if blob&c[1] == 0 || blob&c[2] == 0 {
+			blob &^= c[0]
+		}
+	}
+	return blob
+}
+
+// pick returns the global tile ID for a terrain cell with the mask.
+// Blob masks without a tile fall back to the tile ignoring inner corners,
+// so tilesets lacking inner corner art still get proper edges.
+func (r *autotileRules) pick(mask int) int {
+	if gid, found := r.tiles[mask]; found {
+		return gid
+	}
+	if r.kind == autotileBlob47 {
+		for _, c := range autotileCorners {
+			if mask&c[1] != 0 && mask&c[2] != 0 {
+				mask |= c[0]
+			}
+		}
+		if gid, found := r.tiles[mask]; found {
+			return gid
+		}
+	}
+	return r.fallback
+}
+
+// chunk returns the autotiled global tile IDs for the area with top-left
+// tile at column col and row, with size cols x rows tiles.
+// inTerrain reports whether a cell belongs to the terrain; it is called for
+// the cells around the area too, so it must handle wrapping or out of
+// bounds cells itself.
+func (r *autotileRules) chunk(col, row, cols, rows int, inTerrain func(col, row int) bool) []int {
+	// evaluate the terrain once per cell, with a border of one cell
+	w := cols + 2
+	terrain := make([]bool, w*(rows+2))
+	for y := range rows + 2 {
+		for x := range w {
+			terrain[y*w+x] = inTerrain(col+x-1, row+y-1)
+		}
+	}
+
+	layer := make([]int, cols*rows)
+	for y := range rows {
+		for x := range cols {
+			if !terrain[(y+1)*w+x+1] {
+				layer[y*cols+x] = r.background
+				continue
+			}
+			m := r.mask(func(dx, dy int) bool {
+				return terrain[(y+1+dy)*w+x+1+dx]
+			})
+			layer[y*cols+x] = r.pick(m)
+		}
+	}
+	return layer
+}
+
+// paintTerrain adds (on=true) or removes the cell at column col and row
+// of layer li to the terrain of the rules, then autotiles the cell and its
+// neighbors. If wrap is true, neighbors across the tilemap edges are the
+// cells at the opposite edges, as seen by a cyclic camera. Otherwise cells
+// beyond the edges count as terrain, so borders are not drawn along them.
+// It returns the tiles changed, in order.
+func (ts *tiles) paintTerrain(li, col, row int, rules *autotileRules, on, wrap bool) []tileEdit {
+	var edits []tileEdit
+	set := func(c, r, gid int) {
+		before := ts.tileAt(li, c, r)
+		if before == gid {
+			return
+		}
+		ts.setTile(li, c, r, gid)
+		edits = append(edits, tileEdit{layer: li, col: c, row: r, before: before, after: gid})
+	}
+
+	if on {
+		if !rules.member(ts.tileAt(li, col, row)) {
+			set(col, row, rules.fallback)
+		}
+	} else {
+		set(col, row, rules.background)
+	}
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			c, r, inside := ts.wrapCell(col+dx, row+dy, wrap)
+			if !inside {
+				continue
+			}
+			if gid, changed := ts.autotileCell(li, c, r, rules, wrap); changed {
+				set(c, r, gid)
+			}
+		}
+	}
+	return edits
+}
+
+// autotileCell picks again the tile of the terrain cell at column col and
+// row of layer li from its neighbors. It reports whether the tile must
+// change. Cells outside the terrain are kept.
+func (ts *tiles) autotileCell(li, col, row int, rules *autotileRules, wrap bool) (int, bool) {
+	current := ts.tileAt(li, col, row)
+	if !rules.member(current) {
+		return current, false
+	}
+	m := rules.mask(func(dx, dy int) bool {
+		c, r, inside := ts.wrapCell(col+dx, row+dy, wrap)
+		if !inside {
+			return true
+		}
+		return rules.member(ts.tileAt(li, c, r))
+	})
+	if m == rules.fullMask() && rules.variants[current] {
+		return current, false
+	}
+	gid := rules.pick(m)
+	return gid, gid != current
+}
+
+// wrapCell returns the cell at column col and row, wrapped around the
+// tilemap edges if wrap is true. inside is false for cells beyond the
+// edges when not wrapping.
+func (ts *tiles) wrapCell(col, row int, wrap bool) (c, r int, inside bool) {
+	if wrap {
+		return torus.WrapInt(col, ts.tileLayerXCount), torus.WrapInt(row, ts.tileLayerYCount), true
+	}
+	inside = col >= 0 && col < ts.tileLayerXCount && row >= 0 && row < ts.tileLayerYCount
+	return col, row, inside
+}
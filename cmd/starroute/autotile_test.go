@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/udhos/starroute/torus"
+)
+
+type autotileTest struct {
+	name   string
+	rules  *autotileRules
+	mask   []string // terrain cells marked with #, the center cell is tiled
+	expect int
+}
+
+var autotileTestTable = []autotileTest{
+	{
+		name:   "blob inside",
+		rules:  spaceRules,
+		mask:   []string{"###", "###", "###"},
+		expect: tileSpace,
+	},
+	{
+		name:   "blob top edge",
+		rules:  spaceRules,
+		mask:   []string{"...", "###", "###"},
+		expect: 194,
+	},
+	{
+		name:   "blob top-left corner",
+		rules:  spaceRules,
+		mask:   []string{"...", ".##", ".##"},
+		expect: 193,
+	},
+	{
+		name:   "blob corner ignored without both edges",
+		rules:  spaceRules,
+		mask:   []string{"#..", "###", "###"},
+		expect: 194,
+	},
+	{
+		name:   "blob inner corner",
+		rules:  spaceRules,
+		mask:   []string{"###", "###", "##."},
+		expect: 197,
+	},
+	{
+		name:   "blob edge with inner corner falls back to edge",
+		rules:  spaceRules,
+		mask:   []string{"...", "###", "##."},
+		expect: 194,
+	},
+	{
+		name:   "blob isolated falls back",
+		rules:  spaceRules,
+		mask:   []string{"...", ".#.", "..."},
+		expect: tileSpace,
+	},
+	{
+		name:   "wang inside ignores corners",
+		rules:  spaceRulesWang16,
+		mask:   []string{"##.", "###", "###"},
+		expect: tileSpace,
+	},
+	{
+		name:   "wang bottom-right corner",
+		rules:  spaceRulesWang16,
+		mask:   []string{".#.", "##.", "..."},
+		expect: 271,
+	},
+}
+
+// go test -count 1 -run '^TestAutotile$' ./...
+func TestAutotile(t *testing.T) {
+	for i, data := range autotileTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(autotileTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			inTerrain := func(col, row int) bool {
+				return data.mask[row][col] == '#'
+			}
+			got := data.rules.chunk(1, 1, 1, 1, inTerrain)
+			if got[0] != data.expect {
+				t.Errorf("wrong tile: expected %d got %d", data.expect, got[0])
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestAutotileChunks$' ./...
+func TestAutotileChunks(t *testing.T) {
+	// a disc of space in a world of 32x32 tiles, wrapping around
+	inSpace := func(col, row int) bool {
+		dx := torus.WrapInt(col, 32) - 16
+		dy := torus.WrapInt(row, 32) - 16
+		return dx*dx+dy*dy < 100
+	}
+	whole := spaceRules.chunk(0, 0, 32, 32, inSpace)
+	for _, pos := range [][2]int{{0, 0}, {16, 0}, {0, 16}, {16, 16}} {
+		chunk := spaceRules.chunk(pos[0], pos[1], 16, 16, inSpace)
+		for y := range 16 {
+			i := (pos[1]+y)*32 + pos[0]
+			if !slices.Equal(chunk[y*16:y*16+16], whole[i:i+16]) {
+				t.Fatalf("chunk at %v row %d differs from whole layer", pos, y)
+			}
+		}
+	}
+}
+
+type paintTerrainTest struct {
+	name       string
+	wrap       bool
+	col, row   int
+	on         bool
+	checkCol   int
+	checkRow   int
+	expectTile int
+}
+
+// tilemap is 40x40 tiles of space
+var paintTerrainTestTable = []paintTerrainTest{
+	{
+		name: "void painted",
+		col:  20, row: 20,
+		checkCol: 20, checkRow: 20,
+		expectTile: tileVoid,
+	},
+	{
+		name: "neighbor below void gets top edge",
+		col:  20, row: 20,
+		checkCol: 20, checkRow: 21,
+		expectTile: 194,
+	},
+	{
+		name: "diagonal neighbor gets inner corner",
+		col:  20, row: 20,
+		checkCol: 19, checkRow: 19,
+		expectTile: 197,
+	},
+	{
+		name: "no border across the edge without wrap",
+		col:  20, row: 0,
+		checkCol: 20, checkRow: 39,
+		expectTile: tileSpace,
+	},
+	{
+		name: "border across the edge with wrap",
+		wrap: true,
+		col:  20, row: 0,
+		checkCol: 20, checkRow: 39,
+		expectTile: 269,
+	},
+	{
+		name: "space painted back",
+		on:   true,
+		col:  20, row: 20,
+		checkCol: 20, checkRow: 21,
+		expectTile: tileSpace,
+	},
+}
+
+// go test -count 1 -run '^TestPaintTerrain$' ./...
+func TestPaintTerrain(t *testing.T) {
+	for i, data := range paintTerrainTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(paintTerrainTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			ts := newTestTiles(40, singleTileSource(tileSpace))
+			edits := ts.paintTerrain(0, data.col, data.row, spaceRules, false, data.wrap)
+			if data.on {
+				edits = append(edits, ts.paintTerrain(0, data.col, data.row, spaceRules, true, data.wrap)...)
+			}
+			if got := ts.tileAt(0, data.checkCol, data.checkRow); got != data.expectTile {
+				t.Errorf("wrong tile: expected %d got %d", data.expectTile, got)
+			}
+
+			// the edits undone in reverse restore plain space
+			for i := len(edits) - 1; i >= 0; i-- {
+				ed := edits[i]
+				if got := ts.tileAt(ed.layer, ed.col, ed.row); got != ed.after {
+					t.Fatalf("edit %d: expected tile %d got %d", i, ed.after, got)
+				}
+				ts.setTile(ed.layer, ed.col, ed.row, ed.before)
+			}
+			if got := ts.tileAt(0, data.checkCol, data.checkRow); got != tileSpace {
+				t.Errorf("undo: expected space, got %d", got)
+			}
+		})
+	}
+}
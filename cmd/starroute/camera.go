@@ -1,15 +1,119 @@
 package main
 
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/udhos/starroute/torus"
+)
+
 type camera struct {
-	x, y   int
+	// x,y is the world position of the top-left corner of the view.
+	// It is snapped to pixels only when drawing, so the camera moves
+	// smoothly at any speed.
+	x, y   float64
+	vx, vy float64 // pan velocity, in world pixels per second
 	sc     *scene
 	cyclic bool
+
+	viewport viewport
+	view     *ebiten.Image // world drawn at 1:1 scale, when zoomed
+
+	// zoom scales the world on the screen: 2 shows the world twice as
+	// large, 0.5 shows twice as much of the world.
+	zoom             float64
+	minZoom, maxZoom float64
+
+	// target is the sprite followed by the camera, nil for none.
+	target *sprite
+	follow cameraFollow
+
+	lookX, lookY float64 // current look-ahead offset, in world pixels
+
+	fx cameraFX
+
+	// mouse drag, in screen pixels
+	dragging     bool
+	dragX, dragY float64 // last cursor position
+}
+
+// cameraFollow configures how the camera follows its target.
+type cameraFollow struct {
+	// lerp is the fraction of the distance to the target covered each
+	// tick at camFollowTPS: 1 snaps to the target, smaller values smooth
+	// the motion.
+	lerp float64
+
+	// deadZoneWidth and deadZoneHeight are the size, in screen pixels,
+	// of the rectangle around the screen center where the target moves
+	// freely without moving the camera.
+	deadZoneWidth, deadZoneHeight float64
+
+	// lookAhead is how many seconds of target velocity the camera leads
+	// the target by, so the player sees more of where it is heading.
+	lookAhead float64
 }
 
-const camPanStep = 5
+var defaultCameraFollow = cameraFollow{
+	lerp:           0.08,
+	deadZoneWidth:  160,
+	deadZoneHeight: 100,
+	lookAhead:      0.4,
+}
+
+// camera panning, in screen pixels per second, so the view pans at the
+// same screen speed whatever the zoom
+const (
+	camPanSpeed = 300 // top speed
+	camPanAccel = 1500
+	camPanDecel = 2400
+)
+
+// camera mouse drag
+const (
+	// camDragSmoothing is the weight of the latest tick in the drag
+	// velocity, which becomes the fling velocity on release.
+	camDragSmoothing = 0.3
+
+	// camEdgeMargin is the width, in screen pixels, of the screen border
+	// where the cursor scrolls the camera.
+	camEdgeMargin = 16
+)
+
+// camFollowTPS is the tick rate the follow smoothing is tuned for.
+const camFollowTPS = 60
+
+// default zoom limits of scenes
+const (
+	camZoomMin = 0.5
+	camZoomMax = 4
+)
+
+const (
+	// camZoomStep is the zoom factor of one mouse wheel notch.
+	camZoomStep = 1.1
+
+	// camZoomKeyStep is the zoom factor of each tick a zoom key is held.
+	camZoomKeyStep = 1.02
+)
 
 func newCamera(sc *scene, cyclic, centralize bool) *camera {
-	c := &camera{sc: sc, cyclic: cyclic}
+	c := &camera{
+		sc:      sc,
+		cyclic:  cyclic,
+		zoom:    1,
+		minZoom: camZoomMin,
+		maxZoom: camZoomMax,
+		follow:  defaultCameraFollow,
+
+		viewport: viewportFull,
+	}
+	if sc.opt.minZoom > 0 {
+		c.minZoom = sc.opt.minZoom
+	}
+	if sc.opt.maxZoom > 0 {
+		c.maxZoom = sc.opt.maxZoom
+	}
 
 	if centralize {
 		c.centralize()
@@ -27,51 +131,252 @@ func (c *camera) mid() (int, int) {
 
 // centralize centers the camera on the current scene.
 func (c *camera) centralize() {
-	c.x, c.y = c.mid()
+	midX, midY := c.mid()
+	c.x, c.y = float64(midX), float64(midY)
 	c.clamp()
 }
 
+// world returns the world seen by the camera: the tilemap wrapping
+// around its edges for a cyclic camera, or a flat plane.
+func (c *camera) world() torus.Torus {
+	if !c.cyclic {
+		return torus.Torus{}
+	}
+	return c.sc.tiles.world()
+}
+
 // clamp forces the camera to remain within the tilemap.
 func (c *camera) clamp() {
 	if c.cyclic {
 		// cyclic camera wraps around tilemap edges
-		if c.x < 0 {
-			c.x += c.sc.tiles.tilePixelWidth()
-		} else {
-			c.x = c.x % c.sc.tiles.tilePixelWidth()
-		}
-		if c.y < 0 {
-			c.y += c.sc.tiles.tilePixelHeight()
-		} else {
-			c.y = c.y % c.sc.tiles.tilePixelHeight()
-		}
+		c.x, c.y = c.world().Normalize(c.x, c.y)
 		return
 	}
 	// non-cyclic camera cannot cross tilemap edges
-	c.x = max(min(c.x, c.maxX()), 0)
-	c.y = max(min(c.y, c.maxY()), 0)
+	c.x = max(min(c.x, float64(c.maxX())), 0)
+	c.y = max(min(c.y, float64(c.maxY())), 0)
 }
 
-func (c *camera) stepUp() {
-	c.y -= camPanStep
+// origin returns the camera position snapped down to the world pixel
+// grid, and the fraction of world pixel left over, in screen pixels
+// snapped down to the screen pixel grid. The world is drawn from the
+// whole pixel, so tiles meet without seams, then shifted left and up
+// by the screen pixels. The screen shake is included.
+func (c *camera) origin() (x, y int, shiftX, shiftY float64) {
+	cx := c.x + c.fx.shakeX/c.zoom
+	cy := c.y + c.fx.shakeY/c.zoom
+	cx, cy = c.world().Normalize(cx, cy)
+	fx, fy := math.Floor(cx), math.Floor(cy)
+	return int(fx), int(fy),
+		math.Floor((cx - fx) * c.zoom), math.Floor((cy - fy) * c.zoom)
+}
+
+// centerOn moves the camera to center the view at the world point x,y,
+// stopping it there.
+func (c *camera) centerOn(x, y float64) {
+	w, h := c.viewSize()
+	c.x, c.y = x-float64(w)/2, y-float64(h)/2
+	c.vx, c.vy = 0, 0
+	c.clamp()
+}
+
+// setTarget makes the camera follow spr, or stop following if spr is nil.
+func (c *camera) setTarget(spr *sprite) {
+	c.target = spr
+	c.lookX, c.lookY = 0, 0
+}
+
+// update advances the camera effects and scripted moves by dt seconds,
+// or else moves the camera towards its target, if any.
+func (c *camera) update(dt float64) {
+	scripted := c.scripted() // may end in this update
+	c.updateFX(dt)
+	if scripted || c.target == nil {
+		return
+	}
+	f := c.follow
+
+	// lerp scaled to the elapsed time, so smoothing does not depend on
+	// the tick rate
+	lerp := 1 - math.Pow(1-f.lerp, dt*camFollowTPS)
+
+	// the look-ahead offset eases towards the target velocity, so the
+	// view does not jump when the target turns
+	c.lookX += (c.target.vx*f.lookAhead - c.lookX) * lerp
+	c.lookY += (c.target.vy*f.lookAhead - c.lookY) * lerp
+
+	// follow the target the short way across the wrap seam
+	w, h := c.viewSize()
+	dx, dy := c.world().Displacement(
+		c.x+float64(w)/2, c.y+float64(h)/2,
+		c.target.x+float64(c.target.width)/2+c.lookX,
+		c.target.y+float64(c.target.height)/2+c.lookY)
+
+	dx = deadZone(dx, f.deadZoneWidth/2/c.zoom)
+	dy = deadZone(dy, f.deadZoneHeight/2/c.zoom)
+
+	c.x += dx * lerp
+	c.y += dy * lerp
 	c.clamp()
 }
 
-func (c *camera) stepDown() {
-	c.y += camPanStep
+// deadZone returns how far d is beyond the half-width halfZone of a
+// dead zone centered at zero, or zero if d is inside it.
+func deadZone(d, halfZone float64) float64 {
+	if d > halfZone {
+		return d - halfZone
+	}
+	if d < -halfZone {
+		return d + halfZone
+	}
+	return 0
+}
+
+// pan accelerates the camera towards the direction dirX,dirY over dt
+// seconds, or decelerates it to a stop along an axis where the direction
+// is zero. Directions are -1, 0 or 1.
+func (c *camera) pan(dirX, dirY, dt float64) {
+	if c.dragging {
+		// the mouse holds the camera
+		return
+	}
+	c.vx = panVelocity(c.vx, dirX, c.zoom, dt)
+	c.vy = panVelocity(c.vy, dirY, c.zoom, dt)
+	if c.vx == 0 && c.vy == 0 {
+		return
+	}
+	x, y := c.x+c.vx*dt, c.y+c.vy*dt
+	c.x, c.y = x, y
 	c.clamp()
+	// stop at the tilemap edges
+	if c.x != x && !c.cyclic {
+		c.vx = 0
+	}
+	if c.y != y && !c.cyclic {
+		c.vy = 0
+	}
 }
 
-func (c *camera) stepLeft() {
-	c.x += camPanStep
+// dragStart grabs the world under the screen position sx,sy.
+func (c *camera) dragStart(sx, sy float64) {
+	c.dragging = true
+	c.dragX, c.dragY = sx, sy
+	c.vx, c.vy = 0, 0
+}
+
+// dragMove keeps the grabbed world under the cursor moved to sx,sy over
+// dt seconds.
+func (c *camera) dragMove(sx, sy, dt float64) {
+	if !c.dragging {
+		return
+	}
+	dx := -(sx - c.dragX) / c.zoom
+	dy := -(sy - c.dragY) / c.zoom
+	c.dragX, c.dragY = sx, sy
+
+	x, y := c.x+dx, c.y+dy
+	c.x, c.y = x, y
 	c.clamp()
+	if !c.cyclic {
+		// against the tilemap edges only the allowed motion counts
+		dx += c.x - x
+		dy += c.y - y
+	}
+
+	// the velocity of the last ticks, for the fling
+	c.vx = lerp(c.vx, dx/dt, camDragSmoothing)
+	c.vy = lerp(c.vy, dy/dt, camDragSmoothing)
 }
 
-func (c *camera) stepRight() {
-	c.x -= camPanStep
+// dragEnd releases the world, flinging the camera with the drag velocity.
+// pan slows the fling down to a stop.
+func (c *camera) dragEnd() {
+	c.dragging = false
+}
+
+// edgeDirection returns the pan direction of the cursor at the screen
+// position sx,sy near the border of the camera viewport, or zero if it is
+// away from the border or outside the viewport.
+func (c *camera) edgeDirection(sx, sy float64) (dirX, dirY float64) {
+	r := c.screenRect()
+	x, y := sx-float64(r.Min.X), sy-float64(r.Min.Y)
+	w, h := float64(r.Dx()), float64(r.Dy())
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return 0, 0
+	}
+	switch {
+	case x < camEdgeMargin:
+		dirX = -1
+	case x >= w-camEdgeMargin:
+		dirX = 1
+	}
+	switch {
+	case y < camEdgeMargin:
+		dirY = -1
+	case y >= h-camEdgeMargin:
+		dirY = 1
+	}
+	return dirX, dirY
+}
+
+// panVelocity returns the pan velocity v, in world pixels per second,
+// changed over dt seconds towards the top speed in direction dir.
+func panVelocity(v, dir, zoom, dt float64) float64 {
+	goal := dir * camPanSpeed / zoom
+	rate := camPanAccel / zoom
+	if dir == 0 || v*dir < 0 {
+		// stopping or reversing
+		rate = camPanDecel / zoom
+	}
+	step := rate * dt
+	if math.Abs(goal-v) <= step {
+		return goal
+	}
+	if goal > v {
+		return v + step
+	}
+	return v - step
+}
+
+// viewSize returns the size of the world area shown on the screen, in
+// world pixels.
+func (c *camera) viewSize() (int, int) {
+	r := c.screenRect()
+	return int(math.Ceil(float64(r.Dx()) / c.zoom)),
+		int(math.Ceil(float64(r.Dy()) / c.zoom))
+}
+
+// setZoom changes the zoom within the limits, keeping the world point
+// under the screen position anchorX,anchorY in place.
+// A cyclic camera cannot zoom out beyond the world size, since the world
+// would be shown more than once.
+func (c *camera) setZoom(zoom float64, anchorX, anchorY int) {
+	r := c.screenRect()
+	lo := c.minZoom
+	if c.cyclic {
+		lo = max(lo,
+			float64(r.Dx())/float64(c.sc.tiles.tilePixelWidth()),
+			float64(r.Dy())/float64(c.sc.tiles.tilePixelHeight()))
+	}
+	zoom = max(lo, min(zoom, c.maxZoom))
+
+	// world point under the anchor
+	ax, ay := float64(anchorX-r.Min.X), float64(anchorY-r.Min.Y)
+	wx := c.x + ax/c.zoom
+	wy := c.y + ay/c.zoom
+
+	c.zoom = zoom
+	c.x = wx - ax/zoom
+	c.y = wy - ay/zoom
 	c.clamp()
 }
 
+// zoomBy multiplies the zoom by factor, anchored at the screen position
+// anchorX,anchorY.
+func (c *camera) zoomBy(factor float64, anchorX, anchorY int) {
+	c.setZoom(c.zoom*factor, anchorX, anchorY)
+}
+
 // maxX returns the maximum x coordinate the camera can reach.
 // maxX restricts the non-cyclic camera within the tilemap.
 // for cyclic cameras, it returns the rightmost pixel coordinate before resetting to 0.
@@ -79,7 +384,8 @@ func (c *camera) maxX() int {
 	if c.cyclic {
 		return c.sc.tiles.tilePixelWidth() - 1
 	}
-	return c.sc.tiles.tilePixelWidth() - c.sc.g.screenWidth
+	w, _ := c.viewSize()
+	return c.sc.tiles.tilePixelWidth() - w
 }
 
 // maxY returns the maximum y coordinate the camera can reach.
@@ -89,5 +395,6 @@ func (c *camera) maxY() int {
 	if c.cyclic {
 		return c.sc.tiles.tilePixelHeight() - 1
 	}
-	return c.sc.tiles.tilePixelHeight() - c.sc.g.screenHeight
+	_, h := c.viewSize()
+	return c.sc.tiles.tilePixelHeight() - h
 }
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// newTestScene creates a scene of 800x600 screen pixels over square
+// tilemaps of tileEdgeCount tiles of 16 pixels.
+func newTestScene(tileEdgeCount int, cyclic bool, opt sceneOptions) *scene {
+	sc := &scene{
+		g:     &game{screenWidth: 800, screenHeight: 600},
+		tiles: newTestTiles(tileEdgeCount, singleTileSource(tileSpace)),
+		opt:   opt,
+	}
+	sc.cam = newCamera(sc, cyclic, false)
+	sc.cams = []*camera{sc.cam}
+	return sc
+}
+
+type cameraZoomTest struct {
+	name             string
+	tileEdgeCount    int
+	cyclic           bool
+	opt              sceneOptions
+	camX, camY       float64
+	zoom             float64
+	anchorX, anchorY int
+	expectZoom       float64
+	expectX, expectY float64
+	expectMaxX       int
+}
+
+var cameraZoomTestTable = []cameraZoomTest{
+	{
+		name:          "zoom in at origin",
+		tileEdgeCount: 100, // 1600x1600
+		zoom:          2,
+		expectZoom:    2,
+		expectMaxX:    1600 - 400,
+	},
+	{
+		name:          "zoom in anchored at the cursor",
+		tileEdgeCount: 100,
+		camX:          100, camY: 100,
+		zoom:    2,
+		anchorX: 400, anchorY: 300,
+		expectZoom: 2,
+		expectX:    300, expectY: 250,
+		expectMaxX: 1600 - 400,
+	},
+	{
+		name:          "zoom out clamped to the scene limit",
+		tileEdgeCount: 100,
+		zoom:          0.1,
+		expectZoom:    camZoomMin,
+		expectMaxX:    1600 - 1600,
+	},
+	{
+		name:          "zoom in clamped to the scene limit",
+		tileEdgeCount: 100,
+		opt:           sceneOptions{maxZoom: 3},
+		zoom:          10,
+		expectZoom:    3,
+		expectMaxX:    1600 - 267,
+	},
+	{
+		name:          "cyclic cannot show the world twice",
+		tileEdgeCount: 80, // 1280x1280
+		cyclic:        true,
+		opt:           sceneOptions{minZoom: 0.1},
+		zoom:          0.1,
+		expectZoom:    800.0 / 1280,
+		expectMaxX:    1280 - 1,
+	},
+	{
+		name:          "cyclic anchor wraps around",
+		tileEdgeCount: 80,
+		cyclic:        true,
+		zoom:          2,
+		anchorX:       0, anchorY: 0,
+		camX: 10, camY: 10,
+		expectZoom: 2,
+		expectX:    10, expectY: 10,
+		expectMaxX: 1280 - 1,
+	},
+}
+
+// go test -count 1 -run '^TestCameraZoom$' ./...
+func TestCameraZoom(t *testing.T) {
+	for i, data := range cameraZoomTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(cameraZoomTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			sc := newTestScene(data.tileEdgeCount, data.cyclic, data.opt)
+			cam := sc.cam
+			cam.x, cam.y = data.camX, data.camY
+			cam.setZoom(data.zoom, data.anchorX, data.anchorY)
+			if cam.zoom != data.expectZoom {
+				t.Errorf("wrong zoom: expected %v got %v", data.expectZoom, cam.zoom)
+			}
+			if cam.x != data.expectX || cam.y != data.expectY {
+				t.Errorf("wrong position: expected %v,%v got %v,%v",
+					data.expectX, data.expectY, cam.x, cam.y)
+			}
+			if got := cam.maxX(); got != data.expectMaxX {
+				t.Errorf("wrong maxX: expected %d got %d", data.expectMaxX, got)
+			}
+		})
+	}
+}
+
+type cameraFollowTest struct {
+	name             string
+	cyclic           bool
+	follow           cameraFollow
+	camX, camY       float64
+	targetX, targetY float64
+	vx, vy           float64
+	expectX, expectY float64
+}
+
+// the target sprite is 16x16, the view 800x600 over a 1280x1280 world
+var cameraFollowTestTable = []cameraFollowTest{
+	{
+		name:    "snap to target",
+		follow:  cameraFollow{lerp: 1},
+		targetX: 592, targetY: 392,
+		expectX: 200, expectY: 100,
+	},
+	{
+		name:    "smoothing covers part of the distance",
+		follow:  cameraFollow{lerp: 0.5},
+		targetX: 592, targetY: 392,
+		expectX: 100, expectY: 50,
+	},
+	{
+		name:    "inside dead zone",
+		follow:  cameraFollow{lerp: 1, deadZoneWidth: 200, deadZoneHeight: 200},
+		targetX: 472, targetY: 372,
+		expectX: 0, expectY: 0,
+	},
+	{
+		name:    "beyond dead zone",
+		follow:  cameraFollow{lerp: 1, deadZoneWidth: 200, deadZoneHeight: 200},
+		targetX: 592, targetY: 392,
+		expectX: 100, expectY: 0,
+	},
+	{
+		name:    "look ahead",
+		follow:  cameraFollow{lerp: 1, lookAhead: 0.5},
+		targetX: 592, targetY: 392,
+		vx: 100, vy: -100,
+		expectX: 250, expectY: 50,
+	},
+	{
+		name:    "clamped at the edge",
+		follow:  cameraFollow{lerp: 1},
+		targetX: 1272, targetY: 1272,
+		expectX: 1280 - 800, expectY: 1280 - 600,
+	},
+	{
+		name:    "cyclic the short way across the seam",
+		cyclic:  true,
+		follow:  cameraFollow{lerp: 0.5},
+		targetX: 1192, targetY: 292,
+		expectX: 1040, expectY: 0,
+	},
+	{
+		name:   "cyclic wraps to the start",
+		cyclic: true,
+		follow: cameraFollow{lerp: 1},
+		camX:   1250, camY: 0,
+		targetX: 392, targetY: 292,
+		expectX: 0, expectY: 0,
+	},
+}
+
+// go test -count 1 -run '^TestCameraFollow$' ./...
+func TestCameraFollow(t *testing.T) {
+	for i, data := range cameraFollowTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(cameraFollowTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			sc := newTestScene(80, data.cyclic, sceneOptions{})
+			cam := sc.cam
+			cam.x, cam.y = data.camX, data.camY
+			cam.follow = data.follow
+			cam.setTarget(&sprite{x: data.targetX, y: data.targetY,
+				width: 16, height: 16, vx: data.vx, vy: data.vy})
+			cam.update(1.0 / camFollowTPS)
+			if cam.x != data.expectX || cam.y != data.expectY {
+				t.Errorf("wrong position: expected %v,%v got %v,%v",
+					data.expectX, data.expectY, cam.x, cam.y)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestCameraPan$' ./...
+func TestCameraPan(t *testing.T) {
+	// the same second of panning at different tick rates
+	for _, tps := range []int{30, 60, 120} {
+		sc := newTestScene(1000, false, sceneOptions{})
+		cam := sc.cam
+		dt := 1 / float64(tps)
+		for range tps {
+			cam.pan(1, 0, dt)
+		}
+		if cam.vx != camPanSpeed {
+			t.Errorf("tps=%d: wrong top speed: expected %v got %v", tps, camPanSpeed, cam.vx)
+		}
+		// accelerating for camPanSpeed/camPanAccel seconds then at top speed
+		accel := float64(camPanSpeed) / camPanAccel
+		expect := camPanSpeed*accel/2 + camPanSpeed*(1-accel)
+		if math.Abs(cam.x-expect) > camPanSpeed*dt {
+			t.Errorf("tps=%d: wrong position: expected %v got %v", tps, expect, cam.x)
+		}
+		for range tps {
+			cam.pan(0, 0, dt)
+		}
+		if cam.vx != 0 {
+			t.Errorf("tps=%d: camera did not stop: speed %v", tps, cam.vx)
+		}
+	}
+}
+
+type cameraOriginTest struct {
+	name                 string
+	x, y, zoom           float64
+	expectX, expectY     int
+	expectShX, expectShY float64
+}
+
+var cameraOriginTestTable = []cameraOriginTest{
+	{
+		name: "whole pixels",
+		x:    10, y: 20, zoom: 2,
+		expectX: 10, expectY: 20,
+	},
+	{
+		name: "fraction hidden at 1:1",
+		x:    10.75, y: 20.25, zoom: 1,
+		expectX: 10, expectY: 20,
+	},
+	{
+		name: "fraction shifts zoomed view",
+		x:    10.75, y: 20.25, zoom: 4,
+		expectX: 10, expectY: 20,
+		expectShX: 3, expectShY: 1,
+	},
+	{
+		name: "shift snapped to screen pixels",
+		x:    10.7, y: 20.2, zoom: 4,
+		expectX: 10, expectY: 20,
+		expectShX: 2, expectShY: 0,
+	},
+}
+
+// go test -count 1 -run '^TestCameraOrigin$' ./...
+func TestCameraOrigin(t *testing.T) {
+	for i, data := range cameraOriginTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(cameraOriginTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			cam := &camera{x: data.x, y: data.y, zoom: data.zoom}
+			x, y, shX, shY := cam.origin()
+			if x != data.expectX || y != data.expectY {
+				t.Errorf("wrong origin: expected %d,%d got %d,%d",
+					data.expectX, data.expectY, x, y)
+			}
+			if shX != data.expectShX || shY != data.expectShY {
+				t.Errorf("wrong shift: expected %v,%v got %v,%v",
+					data.expectShX, data.expectShY, shX, shY)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestCameraDrag$' ./...
+func TestCameraDrag(t *testing.T) {
+	const dt = 1.0 / 60
+
+	sc := newTestScene(80, true, sceneOptions{})
+	cam := sc.cam
+	cam.x, cam.y = 10, 10
+	cam.zoom = 2
+
+	// the world follows the cursor, across the wrap seam
+	cam.dragStart(100, 100)
+	for i := range 10 {
+		cam.dragMove(100+float64(i+1)*4, 100, dt)
+	}
+	if cam.x != 1280-10 || cam.y != 10 {
+		t.Errorf("wrong position after drag: %v,%v", cam.x, cam.y)
+	}
+	cam.pan(1, 1, dt)
+	if cam.x != 1280-10 || cam.y != 10 {
+		t.Errorf("pan moved the dragged camera: %v,%v", cam.x, cam.y)
+	}
+	if cam.vx >= 0 {
+		t.Fatalf("wrong drag velocity: %v", cam.vx)
+	}
+
+	// the fling keeps moving the camera after the release, then stops
+	cam.dragEnd()
+	x := cam.x
+	cam.pan(0, 0, dt)
+	if cam.x >= x {
+		t.Errorf("no fling: %v", cam.x)
+	}
+	for range 120 {
+		cam.pan(0, 0, dt)
+	}
+	if cam.vx != 0 || cam.vy != 0 {
+		t.Errorf("fling did not stop: %v,%v", cam.vx, cam.vy)
+	}
+
+	// the tilemap edges stop the non-cyclic fling
+	sc = newTestScene(80, false, sceneOptions{})
+	cam = sc.cam
+	cam.x, cam.y = 100, 100
+	cam.dragStart(0, 0)
+	cam.dragMove(50, 0, dt)
+	cam.dragMove(100, 0, dt)
+	if cam.x != 0 {
+		t.Errorf("drag crossed the edge: %v", cam.x)
+	}
+	cam.dragEnd()
+	cam.pan(0, 0, dt)
+	if cam.x != 0 || cam.vx != 0 {
+		t.Errorf("fling crossed the edge: %v at %v", cam.x, cam.vx)
+	}
+}
+
+type edgeDirectionTest struct {
+	name             string
+	v                viewport
+	x, y             float64
+	expectX, expectY float64
+}
+
+var edgeDirectionTestTable = []edgeDirectionTest{
+	{name: "center", v: viewportFull, x: 400, y: 300},
+	{name: "left", v: viewportFull, x: 0, y: 300, expectX: -1},
+	{name: "bottom right", v: viewportFull, x: 799, y: 590, expectX: 1, expectY: 1},
+	{name: "top", v: viewportFull, x: 400, y: 15, expectY: -1},
+	{name: "outside", v: viewportFull, x: -1, y: 300},
+	{name: "right half left edge", v: viewportRight, x: 405, y: 300, expectX: -1},
+	{name: "left of right half", v: viewportRight, x: 395, y: 300},
+}
+
+// go test -count 1 -run '^TestEdgeDirection$' ./...
+func TestEdgeDirection(t *testing.T) {
+	for i, data := range edgeDirectionTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(edgeDirectionTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			sc := newTestScene(80, false, sceneOptions{})
+			sc.cam.viewport = data.v
+			x, y := sc.cam.edgeDirection(data.x, data.y)
+			if x != data.expectX || y != data.expectY {
+				t.Errorf("wrong direction: expected %v,%v got %v,%v",
+					data.expectX, data.expectY, x, y)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestCameraViewGrows$' ./...
+func TestCameraViewGrows(t *testing.T) {
+	screen := ebiten.NewImage(800, 600)
+	defer screen.Deallocate()
+
+	sc := newTestScene(400, false, sceneOptions{minZoom: 0.25, maxZoom: 8})
+	cam := sc.cam
+	for i, data := range []struct {
+		zoom         float64
+		expectWidth  int
+		expectHeight int
+	}{
+		{2, 401, 301},
+		{0.5, 1601, 1201},
+		{4, 1601, 1201}, // zooming in keeps the larger view
+		{0.25, 3201, 2401},
+	} {
+		cam.setZoom(data.zoom, 0, 0)
+		sc.drawCamera(screen, cam, false)
+		if b := cam.view.Bounds(); b.Dx() != data.expectWidth || b.Dy() != data.expectHeight {
+			t.Errorf("%02d: zoom %v: wrong view size: expected %dx%d got %dx%d",
+				i+1, data.zoom, data.expectWidth, data.expectHeight, b.Dx(), b.Dy())
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// cameraFX holds the effects layered over a camera: screen shake, color
+// flash and fade overlays, and scripted moves. Effects run together and
+// are advanced by camera.update.
+type cameraFX struct {
+	// trauma in [0,1] drives the screen shake. Impacts add trauma, which
+	// decays over time. The shake grows with the square of the trauma, so
+	// small hits are subtle and big ones violent.
+	trauma         float64
+	clock          float64 // seconds, samples the shake noise
+	shakeX, shakeY float64 // current shake offset, in screen pixels
+
+	flash overlay // starts at its alpha and fades out
+	fade  overlay // stays at its final alpha
+
+	// moves are the scripted camera moves, the first one is running.
+	moves        []camMove
+	homeX, homeY float64 // camera position when the script started
+}
+
+// screen shake
+const (
+	camShakeMax       = 16  // offset at full trauma, in screen pixels
+	camShakeDecay     = 0.8 // trauma lost per second
+	camShakeFrequency = 20  // noise lattice cells per second
+	camShakeSeed      = 0x5eed
+)
+
+// overlay is a color drawn over the camera viewport, with its alpha
+// animated from one value to another. The alpha of color is ignored.
+type overlay struct {
+	color            color.RGBA
+	from, to         float64 // alpha
+	seconds, elapsed float64
+}
+
+// alpha returns the current alpha of the overlay.
+func (o *overlay) alpha() float64 {
+	if o.elapsed >= o.seconds {
+		return o.to
+	}
+	return lerp(o.from, o.to, o.elapsed/o.seconds)
+}
+
+func (o *overlay) update(dt float64) {
+	o.elapsed = min(o.elapsed+dt, o.seconds)
+}
+
+// draw fills the rectangle of screen at x,y of size width x height with
+// the overlay color.
+func (o *overlay) draw(screen *ebiten.Image, x, y, width, height float32) {
+	a := o.alpha()
+	if a <= 0 {
+		return
+	}
+	c := color.NRGBA{o.color.R, o.color.G, o.color.B, uint8(math.Round(a * 255))}
+	vector.FillRect(screen, x, y, width, height, c, false)
+}
+
+// easing maps the linear progress t in [0,1] of a move to the eased one.
+type easing func(t float64) float64
+
+// easeLinear moves at constant speed, the default of scripted moves.
+func easeLinear(t float64) float64 { return t }
+
+func easeInOutCubic(t float64) float64 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	u := -2*t + 2
+	return 1 - u*u*u/2
+}
+
+// camMove is a scripted move of the camera.
+type camMove struct {
+	toX, toY float64 // camera position at the end of the move
+	back     bool    // move back to the position before the script
+	wait     bool    // hold the position for the duration
+	seconds  float64
+	ease     easing // nil moves linearly
+
+	started      bool
+	fromX, fromY float64
+	elapsed      float64
+}
+
+// shake adds trauma to the camera, up to the maximum of 1.
+func (c *camera) shake(trauma float64) {
+	c.fx.trauma = min(c.fx.trauma+trauma, 1)
+}
+
+// flash covers the viewport with col at alpha, in [0,1], fading it out
+// over seconds.
+func (c *camera) flash(col color.RGBA, alpha, seconds float64) {
+	c.fx.flash = overlay{color: col, from: alpha, seconds: seconds}
+}
+
+// fade fades the viewport into col until its alpha reaches to, in [0,1],
+// over seconds. Fading to 0 fades back in.
+func (c *camera) fade(col color.RGBA, to, seconds float64) {
+	c.fx.fade = overlay{color: col, from: c.fx.fade.alpha(), to: to, seconds: seconds}
+}
+
+// scripted reports whether the camera is running scripted moves, which
+// take over from following the target.
+func (c *camera) scripted() bool {
+	return len(c.fx.moves) > 0
+}
+
+// script queues a move, starting the script if there is none running.
+func (c *camera) script(m camMove) {
+	if !c.scripted() {
+		c.fx.homeX, c.fx.homeY = c.x, c.y
+	}
+	if m.ease == nil {
+		m.ease = easeLinear
+	}
+	c.fx.moves = append(c.fx.moves, m)
+}
+
+// panTo queues a move centering the view at the world point x,y over
+// seconds, eased by ease, or linear if nil.
+func (c *camera) panTo(x, y, seconds float64, ease easing) {
+	w, h := c.viewSize()
+	c.script(camMove{toX: x - float64(w)/2, toY: y - float64(h)/2, seconds: seconds, ease: ease})
+}
+
+// hold queues a pause of the scripted moves.
+func (c *camera) hold(seconds float64) {
+	c.script(camMove{wait: true, seconds: seconds})
+}
+
+// panBack queues a move back to where the camera was when the script
+// started, eased by ease, or linear if nil.
+func (c *camera) panBack(seconds float64, ease easing) {
+	c.script(camMove{back: true, seconds: seconds, ease: ease})
+}
+
+// updateFX advances the camera effects by dt seconds.
+func (c *camera) updateFX(dt float64) {
+	fx := &c.fx
+
+	fx.clock += dt
+	fx.trauma = max(fx.trauma-camShakeDecay*dt, 0)
+	if fx.trauma > 0 {
+		// smooth noise centered at zero, independent along each axis
+		t := fx.clock * camShakeFrequency
+		amount := camShakeMax * fx.trauma * fx.trauma
+		fx.shakeX = amount * (2*valueNoise(camShakeSeed, t, 0, 0, 0) - 1)
+		fx.shakeY = amount * (2*valueNoise(camShakeSeed, t, 100, 0, 0) - 1)
+	} else {
+		fx.shakeX, fx.shakeY = 0, 0
+	}
+
+	fx.flash.update(dt)
+	fx.fade.update(dt)
+
+	if c.scripted() {
+		c.updateMove(dt)
+	}
+}
+
+// updateMove advances the running scripted move by dt seconds.
+func (c *camera) updateMove(dt float64) {
+	fx := &c.fx
+	m := &fx.moves[0]
+
+	if !m.started {
+		m.started = true
+		m.fromX, m.fromY = c.x, c.y
+		if m.back {
+			m.toX, m.toY = fx.homeX, fx.homeY
+		}
+		if m.wait {
+			m.toX, m.toY = c.x, c.y
+		}
+	}
+
+	m.elapsed += dt
+	t := 1.0
+	if m.seconds > 0 {
+		t = min(m.elapsed/m.seconds, 1)
+	}
+
+	// the short way across the wrap seam
+	dx, dy := c.world().Displacement(m.fromX, m.fromY, m.toX, m.toY)
+	e := m.ease(t)
+	c.x = m.fromX + dx*e
+	c.y = m.fromY + dy*e
+	c.clamp()
+
+	if t >= 1 {
+		fx.moves = fx.moves[1:]
+	}
+}
+
+// drawFX draws the overlays of the camera over its viewport.
+func (c *camera) drawFX(screen *ebiten.Image) {
+	r := c.screenRect()
+	x, y := float32(r.Min.X), float32(r.Min.Y)
+	w, h := float32(r.Dx()), float32(r.Dy())
+	c.fx.fade.draw(screen, x, y, w, h)
+	c.fx.flash.draw(screen, x, y, w, h)
+}
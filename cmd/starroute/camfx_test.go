@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// go test -count 1 -run '^TestCameraShake$' ./...
+func TestCameraShake(t *testing.T) {
+	sc := newTestScene(80, false, sceneOptions{})
+	cam := sc.cam
+	cam.x, cam.y = 100, 100
+
+	cam.shake(0.7)
+	cam.shake(0.7)
+	if cam.fx.trauma != 1 {
+		t.Fatalf("trauma not capped: %v", cam.fx.trauma)
+	}
+
+	const dt = 1.0 / 60
+	var moved bool
+	for range 60 {
+		cam.update(dt)
+		if math.Abs(cam.fx.shakeX) > camShakeMax || math.Abs(cam.fx.shakeY) > camShakeMax {
+			t.Fatalf("shake too large: %v,%v", cam.fx.shakeX, cam.fx.shakeY)
+		}
+		if x, y, _, _ := cam.origin(); x != 100 || y != 100 {
+			moved = true
+		}
+	}
+	if !moved {
+		t.Errorf("shake did not move the view")
+	}
+	if cam.x != 100 || cam.y != 100 {
+		t.Errorf("shake moved the camera: %v,%v", cam.x, cam.y)
+	}
+
+	for range 120 {
+		cam.update(dt)
+	}
+	if cam.fx.trauma != 0 || cam.fx.shakeX != 0 || cam.fx.shakeY != 0 {
+		t.Errorf("shake did not settle: trauma=%v shake=%v,%v",
+			cam.fx.trauma, cam.fx.shakeX, cam.fx.shakeY)
+	}
+}
+
+// go test -count 1 -run '^TestCameraOverlays$' ./...
+func TestCameraOverlays(t *testing.T) {
+	sc := newTestScene(80, false, sceneOptions{})
+	cam := sc.cam
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	black := color.RGBA{0, 0, 0, 0xff}
+
+	cam.flash(white, 0.8, 1)
+	cam.fade(black, 1, 2)
+	cam.update(0.5)
+	if a := cam.fx.flash.alpha(); a != 0.4 {
+		t.Errorf("wrong flash alpha: %v", a)
+	}
+	if a := cam.fx.fade.alpha(); a != 0.25 {
+		t.Errorf("wrong fade alpha: %v", a)
+	}
+
+	// fading back in starts from the current alpha
+	cam.fade(black, 0, 1)
+	cam.update(0.5)
+	if a := cam.fx.fade.alpha(); a != 0.125 {
+		t.Errorf("wrong fade in alpha: %v", a)
+	}
+
+	cam.update(1)
+	if a := cam.fx.flash.alpha(); a != 0 {
+		t.Errorf("flash did not end: %v", a)
+	}
+	if a := cam.fx.fade.alpha(); a != 0 {
+		t.Errorf("fade in did not end: %v", a)
+	}
+}
+
+type easingTest struct {
+	name string
+	ease easing
+}
+
+var easingTestTable = []easingTest{
+	{name: "linear", ease: easeLinear},
+	{name: "in out cubic", ease: easeInOutCubic},
+}
+
+// go test -count 1 -run '^TestEasing$' ./...
+func TestEasing(t *testing.T) {
+	for i, data := range easingTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(easingTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if e := data.ease(0); e != 0 {
+				t.Errorf("wrong start: %v", e)
+			}
+			if e := data.ease(0.5); e != 0.5 {
+				t.Errorf("wrong middle: %v", e)
+			}
+			if e := data.ease(1); e != 1 {
+				t.Errorf("wrong end: %v", e)
+			}
+			prev := 0.0
+			for s := range 101 {
+				e := data.ease(float64(s) / 100)
+				if e < prev {
+					t.Fatalf("not monotonic at %d: %v < %v", s, e, prev)
+				}
+				prev = e
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestCameraScript$' ./...
+func TestCameraScript(t *testing.T) {
+	sc := newTestScene(80, true, sceneOptions{})
+	cam := sc.cam
+	cam.x, cam.y = 100, 100
+	target := &sprite{x: 100, y: 100, width: 16, height: 16}
+	cam.setTarget(target)
+
+	// view center at 1200,300 is the short way to the left
+	// moves are linear by default
+	cam.panTo(1200, 300, 1, nil)
+	cam.hold(1)
+	cam.panBack(1, nil)
+
+	cam.update(0.5)
+	if cam.x != 1090 || cam.y != 50 {
+		t.Errorf("wrong position half way: %v,%v", cam.x, cam.y)
+	}
+	cam.update(0.5)
+	if cam.x != 800 || cam.y != 0 {
+		t.Errorf("wrong position at the point: %v,%v", cam.x, cam.y)
+	}
+	cam.update(1)
+	if cam.x != 800 || cam.y != 0 {
+		t.Errorf("wrong position holding: %v,%v", cam.x, cam.y)
+	}
+	cam.update(1)
+	if cam.x != 100 || cam.y != 100 {
+		t.Errorf("wrong position back: %v,%v", cam.x, cam.y)
+	}
+	if cam.scripted() {
+		t.Errorf("script did not end")
+	}
+
+	// following resumes after the script
+	target.x = 900
+	cam.update(1)
+	if cam.x == 100 {
+		t.Errorf("camera did not follow the target after the script")
+	}
+}
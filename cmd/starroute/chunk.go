@@ -0,0 +1,286 @@
+package main
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	// tileChunkSize is the number of tiles along each edge of a chunk.
+	tileChunkSize = 16
+
+	// tileChunkEvictTicks is how long an unused chunk is kept in memory.
+	tileChunkEvictTicks = 300
+)
+
+// chunkSource provides the tiles of the world on demand.
+// It returns the global tile IDs for the chunk with top-left tile at
+// column col and row, with size cols x rows tiles, as one slice per layer.
+// A source must return the same tiles every time it is called for the
+// same chunk, since chunks are evicted and loaded again.
+type chunkSource func(col, row, cols, rows int) [][]int
+
+// layerSource provides chunks from whole layers held in memory.
+func layerSource(layers [][]int, tileLayerXCount int) chunkSource {
+	return func(col, row, cols, rows int) [][]int {
+		result := make([][]int, len(layers))
+		for li, l := range layers {
+			chunk := make([]int, 0, cols*rows)
+			for y := range rows {
+				i := (row+y)*tileLayerXCount + col
+				chunk = append(chunk, l[i:i+cols]...)
+			}
+			result[li] = chunk
+		}
+		return result
+	}
+}
+
+// tileChunk is a square block of tiles of all layers.
+// Chunks at the right and bottom edges of the tilemap may be smaller.
+// Layers of a chunk are pre-rendered into images, so that each one can be
+// drawn with a single DrawImage call.
+type tileChunk struct {
+	bounds   image.Rectangle // world pixels covered by the chunk
+	cols     int             // chunk width in tiles
+	layers   [][]int         // global tile IDs, one slice per layer
+	cache    []chunkCache    // pre-rendered layers
+	dirty    bool            // changed by setTile, must not be evicted
+	lastUsed int             // tick of last use
+}
+
+// chunkCache is a pre-rendered layer of a chunk.
+type chunkCache struct {
+	rendered bool
+	image    *ebiten.Image // nil if the layer must be drawn tile by tile
+	empty    bool          // all tiles are empty
+}
+
+// chunkCounts returns the number of chunk columns and rows in the tilemap.
+func (ts *tiles) chunkCounts() (int, int) {
+	return ceilDiv(ts.tileLayerXCount, tileChunkSize), ceilDiv(ts.tileLayerYCount, tileChunkSize)
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// chunk returns the chunk c, loading it from the source on demand.
+func (ts *tiles) chunk(c int) *tileChunk {
+	ch, found := ts.chunks[c]
+	if !found {
+		ch = ts.loadChunk(c)
+		ts.chunks[c] = ch
+	}
+	ch.lastUsed = ts.tick
+	return ch
+}
+
+func (ts *tiles) loadChunk(c int) *tileChunk {
+	tileSize := ts.tileSize
+	chunksX, _ := ts.chunkCounts()
+
+	// chunk position and size in tiles
+	col := (c % chunksX) * tileChunkSize
+	row := (c / chunksX) * tileChunkSize
+	cols := min(tileChunkSize, ts.tileLayerXCount-col)
+	rows := min(tileChunkSize, ts.tileLayerYCount-row)
+
+	return &tileChunk{
+		bounds: image.Rect(col*tileSize, row*tileSize,
+			(col+cols)*tileSize, (row+rows)*tileSize),
+		cols:   cols,
+		layers: ts.source(col, row, cols, rows),
+		cache:  make([]chunkCache, len(ts.layerInfo)),
+	}
+}
+
+// chunkAt returns the chunk holding the tile at column col and row,
+// and the position of the tile within the chunk.
+func (ts *tiles) chunkAt(col, row int) (*tileChunk, int) {
+	chunksX, _ := ts.chunkCounts()
+	ch := ts.chunk((row/tileChunkSize)*chunksX + col/tileChunkSize)
+	return ch, (row%tileChunkSize)*ch.cols + col%tileChunkSize
+}
+
+// tileAt returns the global tile ID at column col and row of layer li.
+func (ts *tiles) tileAt(li, col, row int) int {
+	ch, i := ts.chunkAt(col, row)
+	return ch.layers[li][i]
+}
+
+// peekTiles returns the global tile IDs of all layers at column col and
+// row, without loading the chunk that holds them: tiles of chunks not in
+// memory are read from the source. It samples huge worlds cheaply.
+func (ts *tiles) peekTiles(col, row int) []int {
+	chunksX, _ := ts.chunkCounts()
+	c := (row/tileChunkSize)*chunksX + col/tileChunkSize
+	ch, found := ts.chunks[c]
+	if !found {
+		layers := ts.source(col, row, 1, 1)
+		gids := make([]int, len(layers))
+		for li, l := range layers {
+			gids[li] = l[0]
+		}
+		return gids
+	}
+	i := (row%tileChunkSize)*ch.cols + col%tileChunkSize
+	gids := make([]int, len(ch.layers))
+	for li, l := range ch.layers {
+		gids[li] = l[i]
+	}
+	return gids
+}
+
+// setTile changes the global tile ID at column col and row of layer li,
+// invalidating the pre-rendered layer of the chunk that holds the tile.
+func (ts *tiles) setTile(li, col, row, gid int) {
+	ch, i := ts.chunkAt(col, row)
+	ch.layers[li][i] = gid
+	ch.dirty = true
+	ts.version++
+
+	cc := &ch.cache[li]
+	if cc.image != nil {
+		cc.image.Deallocate()
+	}
+	*cc = chunkCache{}
+}
+
+// prefetch loads the chunks intersecting the world rectangle r,
+// so that they are ready before being drawn.
+func (ts *tiles) prefetch(r image.Rectangle) {
+	r = r.Intersect(image.Rect(0, 0, ts.tilePixelWidth(), ts.tilePixelHeight()))
+	if r.Empty() {
+		return
+	}
+	chunkPixels := tileChunkSize * ts.tileSize
+	chunksX, _ := ts.chunkCounts()
+	for y := r.Min.Y / chunkPixels; y <= (r.Max.Y-1)/chunkPixels; y++ {
+		for x := r.Min.X / chunkPixels; x <= (r.Max.X-1)/chunkPixels; x++ {
+			ts.chunk(y*chunksX + x)
+		}
+	}
+}
+
+// evictChunks releases the chunks unused for tileChunkEvictTicks,
+// except the ones changed by setTile.
+func (ts *tiles) evictChunks() {
+	for c, ch := range ts.chunks {
+		if ch.dirty || ts.tick-ch.lastUsed < tileChunkEvictTicks {
+			continue
+		}
+		for _, cc := range ch.cache {
+			if cc.image != nil {
+				cc.image.Deallocate()
+			}
+		}
+		delete(ts.chunks, c)
+	}
+}
+
+// cached returns layer li of chunk ch pre-rendered into an image.
+// Layers holding animated tiles, or tiles larger than the cell, are
+// not pre-rendered since they would not fit a static chunk image.
+func (ts *tiles) cached(ch *tileChunk, li int) *chunkCache {
+	cc := &ch.cache[li]
+	if cc.rendered {
+		return cc
+	}
+	cc.rendered = true
+	cc.empty = true
+
+	l := ch.layers[li]
+	tileSize := ts.tileSize
+	static := true
+
+	for _, gid := range l {
+		if ts.hidden(gid) {
+			continue
+		}
+		set, t := ts.tilesets.resolve(gid & tileGIDMask)
+		if set == nil {
+			continue
+		}
+		cc.empty = false
+		if _, animated := set.animations[t]; animated ||
+			set.tileWidth > tileSize || set.tileHeight > tileSize {
+			static = false
+		}
+	}
+
+	if cc.empty || !static {
+		return cc
+	}
+
+	cc.image = ebiten.NewImage(ch.bounds.Dx(), ch.bounds.Dy())
+	for i, gid := range l {
+		ts.drawTile(cc.image, gid, (i%ch.cols)*tileSize, (i/ch.cols)*tileSize)
+	}
+
+	return cc
+}
+
+// drawChunk draws the part of layer li of chunk c that intersects the
+// world rectangle window. dx,dy translates world coordinates into screen
+// coordinates. It returns the number of DrawImage calls issued.
+func (ts *tiles) drawChunk(screen *ebiten.Image, li, c int, window image.Rectangle, dx, dy int) int {
+	ch := ts.chunk(c)
+
+	r := window.Intersect(ch.bounds)
+	if r.Empty() {
+		return 0
+	}
+
+	if ts.noChunkCache {
+		return ts.drawTiles(screen, ch, li, r, dx, dy)
+	}
+
+	cc := ts.cached(ch, li)
+	if cc.empty {
+		return 0
+	}
+	if cc.image == nil {
+		return ts.drawTiles(screen, ch, li, r, dx, dy)
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(r.Min.X+dx), float64(r.Min.Y+dy))
+	screen.DrawImage(cc.image.SubImage(r.Sub(ch.bounds.Min)).(*ebiten.Image), op)
+
+	return 1
+}
+
+// drawTiles draws one by one the tiles of layer li of chunk ch that
+// intersect the world rectangle r. dx,dy translates world coordinates
+// into screen coordinates. It returns the number of DrawImage calls issued.
+func (ts *tiles) drawTiles(screen *ebiten.Image, ch *tileChunk, li int, r image.Rectangle, dx, dy int) int {
+
+	var sum int
+
+	l := ch.layers[li]
+	tileSize := ts.tileSize
+	cols := ch.cols
+
+	offset, xAmount, yAmount := findTilemapWindow(len(l), cols, tileSize,
+		r.Min.X-ch.bounds.Min.X, r.Min.Y-ch.bounds.Min.Y, r.Dx(), r.Dy())
+
+	i := offset
+	for range yAmount {
+		for range xAmount {
+			// worldX,worldY is the position in the world where the tile must be drawn
+			// i % cols gives the column of the tile in the chunk
+			// i / cols gives the row of the tile in the chunk
+			worldX := ch.bounds.Min.X + (i%cols)*tileSize
+			worldY := ch.bounds.Min.Y + (i/cols)*tileSize
+			if ts.drawTile(screen, l[i], worldX+dx, worldY+dy) {
+				sum++
+			}
+			i++
+		}
+		i += cols - xAmount
+	}
+
+	return sum
+}
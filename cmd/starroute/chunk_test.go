@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// newTestTiles creates square tilemaps of tileEdgeCount tiles per edge,
+// with the layers of all sources and 16x16 tiles from a blank 400x224 tileset.
+func newTestTiles(tileEdgeCount int, sources ...chunkSource) *tiles {
+	source := func(col, row, cols, rows int) [][]int {
+		var layers [][]int
+		for _, s := range sources {
+			layers = append(layers, s(col, row, cols, rows)...)
+		}
+		return layers
+	}
+	layerCount := len(source(0, 0, 1, 1))
+	ts := newTilesFromSource(16, layerCount, tileEdgeCount, tileEdgeCount, source)
+	ts.tilesets.add(&tileset{
+		name:       "test",
+		image:      ebiten.NewImage(400, 224),
+		firstGID:   1,
+		tileWidth:  16,
+		tileHeight: 16,
+		tileCount:  350,
+		columns:    25,
+	})
+	return ts
+}
+
+type chunkDrawTest struct {
+	name         string
+	noChunkCache bool
+	winX, winY   int
+	winWidth     int
+	winHeight    int
+	expectBlits  int
+}
+
+// tilemap is 40x40 tiles of 16 pixels (640x640), or 3x3 chunks
+var chunkDrawTestTable = []chunkDrawTest{
+	{
+		name:     "one chunk",
+		winWidth: 256, winHeight: 256,
+		expectBlits: 1,
+	},
+	{
+		name:         "one chunk without cache",
+		noChunkCache: true,
+		winWidth:     256, winHeight: 256,
+		expectBlits: 256,
+	},
+	{
+		name: "four chunks",
+		winX: 100, winY: 100, winWidth: 256, winHeight: 256,
+		expectBlits: 4,
+	},
+	{
+		name:     "whole tilemap",
+		winWidth: 1000, winHeight: 1000,
+		expectBlits: 9,
+	},
+	{
+		name:         "whole tilemap without cache",
+		noChunkCache: true,
+		winWidth:     1000, winHeight: 1000,
+		expectBlits: 1600,
+	},
+}
+
+// go test -count 1 -run '^TestChunkDraw$' ./...
+func TestChunkDraw(t *testing.T) {
+	screen := ebiten.NewImage(1000, 1000)
+	defer screen.Deallocate()
+	for i, data := range chunkDrawTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(chunkDrawTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			ts := newTestTiles(40, singleTileSource(tileSpace))
+			ts.noChunkCache = data.noChunkCache
+			blits := ts.drawQuadrant(screen, data.winX, data.winY,
+				data.winWidth, data.winHeight, 0, 0)
+			if blits != data.expectBlits {
+				t.Errorf("wrong blits: expected %d got %d", data.expectBlits, blits)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestChunkInvalidate$' ./...
+func TestChunkInvalidate(t *testing.T) {
+	screen := ebiten.NewImage(640, 640)
+	defer screen.Deallocate()
+
+	ts := newTestTiles(40, singleTileSource(tileSpace), singleTileSource(tileEmpty))
+
+	if blits := ts.drawQuadrant(screen, 0, 0, 640, 640, 0, 0); blits != 9 {
+		t.Errorf("empty layer should not be drawn: blits=%d", blits)
+	}
+
+	ts.setTile(1, 20, 20, tileStar)
+	if ts.chunks[4].cache[1].rendered {
+		t.Errorf("chunk not invalidated")
+	}
+	if got := ts.tileAt(1, 20, 20); got != tileStar {
+		t.Errorf("wrong tile: expected %d got %d", tileStar, got)
+	}
+	if blits := ts.drawQuadrant(screen, 0, 0, 640, 640, 0, 0); blits != 10 {
+		t.Errorf("changed chunk not drawn: blits=%d", blits)
+	}
+
+	// animated tiles are drawn tile by tile
+	set, index := ts.tilesets.resolve(tileStar)
+	set.addAnimation(index, []tileFrame{{index: index, duration: time.Second}})
+	ts.setTile(1, 21, 20, tileStar)
+	if blits := ts.drawQuadrant(screen, 0, 0, 640, 640, 0, 0); blits != 11 {
+		t.Errorf("animated chunk not drawn tile by tile: blits=%d", blits)
+	}
+	if ts.chunks[4].cache[1].image != nil {
+		t.Errorf("animated chunk should not be pre-rendered")
+	}
+}
+
+// BenchmarkDrawQuadrant compares drawing a full HD screen of three
+// layers with and without the chunk cache. See the blits/op metric.
+//
+// go test -run '^$' -bench '^BenchmarkDrawQuadrant$' ./...
+func BenchmarkDrawQuadrant(b *testing.B) {
+	const tileEdgeCount = 120 // 1920x1920
+	screen := ebiten.NewImage(1920, 1080)
+	defer screen.Deallocate()
+
+	for _, noChunkCache := range []bool{true, false} {
+		name := "chunks"
+		if noChunkCache {
+			name = "tiles"
+		}
+		b.Run(name, func(b *testing.B) {
+			gx := newGalaxy(1, tileEdgeCount, tileEdgeCount)
+			ts := newTestTiles(tileEdgeCount, gx.source)
+			ts.noChunkCache = noChunkCache
+			var blits int
+			for b.Loop() {
+				blits = ts.drawQuadrant(screen, 8, 8, 1920, 1080, 0, 0)
+			}
+			b.ReportMetric(float64(blits), "blits/op")
+		})
+	}
+}
+
+// go test -count 1 -run '^TestChunkEvict$' ./...
+func TestChunkEvict(t *testing.T) {
+	ts := newTestTiles(4096, newGalaxy(1, 4096, 4096).source)
+
+	ts.prefetch(image.Rect(0, 0, 800, 600))
+	if len(ts.chunks) != 12 {
+		t.Errorf("wrong prefetched chunks: expected 12 got %d", len(ts.chunks))
+	}
+	generated := ts.tileAt(0, 1, 1)
+
+	ts.setTile(0, 300, 300, tileVoid)
+
+	for range tileChunkEvictTicks {
+		ts.update()
+	}
+	if len(ts.chunks) != 1 {
+		t.Errorf("unused chunks not evicted: %d", len(ts.chunks))
+	}
+
+	if got := ts.tileAt(0, 1, 1); got != generated {
+		t.Errorf("generated chunk changed: expected %d got %d", generated, got)
+	}
+	if got := ts.tileAt(0, 300, 300); got != tileVoid {
+		t.Errorf("changed chunk was evicted: expected %d got %d", tileVoid, got)
+	}
+}
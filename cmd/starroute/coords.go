@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Conversions between the coordinate spaces of a camera:
+// screen pixels, world pixels and tile cells.
+// They follow the camera viewport and zoom, the snapping used for
+// drawing, and the wrap around the tilemap edges of cyclic cameras.
+
+// screenToWorld returns the world position under the screen position
+// sx,sy. Cyclic cameras wrap it into the tilemap.
+func (c *camera) screenToWorld(sx, sy float64) (wx, wy float64) {
+	camX, camY, shiftX, shiftY := c.origin()
+	r := c.screenRect()
+	sx -= float64(r.Min.X)
+	sy -= float64(r.Min.Y)
+	wx = float64(camX) + (sx+shiftX)/c.zoom
+	wy = float64(camY) + (sy+shiftY)/c.zoom
+	return c.world().Normalize(wx, wy)
+}
+
+// worldToScreen returns the screen position of the world position wx,wy.
+// Cyclic cameras show the world more than once across the wrap seams,
+// so they pick the copy of the position nearest to the view center.
+func (c *camera) worldToScreen(wx, wy float64) (sx, sy float64) {
+	camX, camY, shiftX, shiftY := c.origin()
+	w, h := c.viewSize()
+	wx, wy = c.world().Nearest(wx, wy,
+		float64(camX)+float64(w)/2, float64(camY)+float64(h)/2)
+	dx := wx - float64(camX)
+	dy := wy - float64(camY)
+	r := c.screenRect()
+	return dx*c.zoom - shiftX + float64(r.Min.X), dy*c.zoom - shiftY + float64(r.Min.Y)
+}
+
+// worldToTile returns the tile cell containing the world position wx,wy.
+// inside is false if there is no tile there.
+func (c *camera) worldToTile(wx, wy float64) (col, row int, inside bool) {
+	size := float64(c.sc.tiles.tileSize)
+	return c.sc.tiles.wrapCell(int(math.Floor(wx/size)), int(math.Floor(wy/size)), c.cyclic)
+}
+
+// tileToWorld returns the world position of the top-left corner of the
+// tile cell.
+func (c *camera) tileToWorld(col, row int) (wx, wy float64) {
+	size := c.sc.tiles.tileSize
+	return float64(col * size), float64(row * size)
+}
+
+// screenToTile returns the tile cell under the screen position sx,sy.
+// inside is false if there is no tile there.
+func (c *camera) screenToTile(sx, sy float64) (col, row int, inside bool) {
+	return c.worldToTile(c.screenToWorld(sx, sy))
+}
+
+// tileToScreen returns the screen position of the top-left corner of the
+// tile cell.
+func (c *camera) tileToScreen(col, row int) (sx, sy float64) {
+	return c.worldToScreen(c.tileToWorld(col, row))
+}
+
+// hit is what lies under a screen position.
+type hit struct {
+	sprite *sprite // topmost sprite, nil if none
+
+	col, row int  // tile cell
+	inside   bool // the cell is within the tilemap
+	layer    int  // topmost visible layer with a tile in the cell, -1 if none
+	gid      int  // tile in the cell at layer
+}
+
+func (h hit) String() string {
+	var s string
+	if h.sprite != nil {
+		s = fmt.Sprintf("sprite@%.0fx%.0f ", h.sprite.x, h.sprite.y)
+	}
+	if !h.inside {
+		return s + "outside"
+	}
+	if h.layer < 0 {
+		return s + fmt.Sprintf("cell %dx%d empty", h.col, h.row)
+	}
+	return s + fmt.Sprintf("cell %dx%d layer %d gid %d", h.col, h.row, h.layer, h.gid)
+}
+
+// hitTest returns the sprite and the tile under the screen position sx,sy,
+// as seen by the topmost camera there.
+func (sc *scene) hitTest(sx, sy float64) hit {
+	cam := sc.cameraAt(sx, sy)
+	wx, wy := cam.screenToWorld(sx, sy)
+
+	h := hit{layer: -1}
+
+	// sprites are drawn in order, so the last one is on top
+	for i := len(sc.sprites) - 1; i >= 0; i-- {
+		if spr := sc.sprites[i]; spr.contains(wx, wy, cam.world()) {
+			h.sprite = spr
+			break
+		}
+	}
+
+	h.col, h.row, h.inside = cam.worldToTile(wx, wy)
+	if !h.inside {
+		return h
+	}
+	for li := len(sc.tiles.layerInfo) - 1; li >= 0; li-- {
+		if !sc.tiles.layerInfo[li].visible {
+			continue
+		}
+		if gid := sc.tiles.tileAt(li, h.col, h.row); gid != tileEmpty {
+			h.layer, h.gid = li, gid
+			break
+		}
+	}
+
+	return h
+}
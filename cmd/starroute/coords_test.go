@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+type coordsTest struct {
+	name             string
+	cyclic           bool
+	camX, camY, zoom float64
+	screenX, screenY float64
+
+	expectWorldX, expectWorldY float64
+	expectCol, expectRow       int
+	expectInside               bool
+}
+
+// the view is 800x600 over a 1280x1280 world of 16x16 tiles
+var coordsTestTable = []coordsTest{
+	{
+		name: "screen origin",
+		camX: 160, camY: 320, zoom: 1,
+		expectWorldX: 160, expectWorldY: 320,
+		expectCol: 10, expectRow: 20,
+		expectInside: true,
+	},
+	{
+		name: "zoomed in",
+		camX: 160, camY: 320, zoom: 2,
+		screenX: 33, screenY: 64,
+		expectWorldX: 176.5, expectWorldY: 352,
+		expectCol: 11, expectRow: 22,
+		expectInside: true,
+	},
+	{
+		name: "zoomed out",
+		camX: 0, camY: 0, zoom: 0.5,
+		screenX: 100, screenY: 50,
+		expectWorldX: 200, expectWorldY: 100,
+		expectCol: 12, expectRow: 6,
+		expectInside: true,
+	},
+	{
+		name: "sub-pixel camera at 1:1 is snapped",
+		camX: 10.5, camY: 20.75, zoom: 1,
+		screenX: 5, screenY: 5,
+		expectWorldX: 15, expectWorldY: 25,
+		expectCol: 0, expectRow: 1,
+		expectInside: true,
+	},
+	{
+		name: "beyond the edge",
+		camX: 480, camY: 680, zoom: 1,
+		screenX: 799, screenY: 599,
+		expectWorldX: 1279, expectWorldY: 1279,
+		expectCol: 79, expectRow: 79,
+		expectInside: true,
+	},
+	{
+		name:   "cyclic wraps across the seam",
+		cyclic: true,
+		camX:   1000, camY: 1200, zoom: 1,
+		screenX: 300, screenY: 100,
+		expectWorldX: 20, expectWorldY: 20,
+		expectCol: 1, expectRow: 1,
+		expectInside: true,
+	},
+}
+
+// go test -count 1 -run '^TestCoords$' ./...
+func TestCoords(t *testing.T) {
+	for i, data := range coordsTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(coordsTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			sc := newTestScene(80, data.cyclic, sceneOptions{})
+			cam := sc.cam
+			cam.x, cam.y, cam.zoom = data.camX, data.camY, data.zoom
+
+			wx, wy := cam.screenToWorld(data.screenX, data.screenY)
+			if wx != data.expectWorldX || wy != data.expectWorldY {
+				t.Errorf("wrong world position: expected %v,%v got %v,%v",
+					data.expectWorldX, data.expectWorldY, wx, wy)
+			}
+
+			col, row, inside := cam.screenToTile(data.screenX, data.screenY)
+			if col != data.expectCol || row != data.expectRow || inside != data.expectInside {
+				t.Errorf("wrong tile: expected %d,%d inside=%t got %d,%d inside=%t",
+					data.expectCol, data.expectRow, data.expectInside, col, row, inside)
+			}
+
+			// and back to the same screen position
+			sx, sy := cam.worldToScreen(wx, wy)
+			if sx != data.screenX || sy != data.screenY {
+				t.Errorf("wrong screen position: expected %v,%v got %v,%v",
+					data.screenX, data.screenY, sx, sy)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestTileToScreenCyclic$' ./...
+func TestTileToScreenCyclic(t *testing.T) {
+	sc := newTestScene(80, true, sceneOptions{})
+	sc.cam.x, sc.cam.y = 1000, 0
+
+	// the copy of the cell nearest to the view center, across the seam
+	if x, y := sc.cam.tileToScreen(5, 2); x != 360 || y != 32 {
+		t.Errorf("wrong cell screen position: %v,%v", x, y)
+	}
+	// the cell straddling the left screen edge
+	if x, y := sc.cam.tileToScreen(62, 2); x != -8 || y != 32 {
+		t.Errorf("wrong cell screen position: %v,%v", x, y)
+	}
+}
+
+// go test -count 1 -run '^TestHitTest$' ./...
+func TestHitTest(t *testing.T) {
+	sc := newTestScene(80, true, sceneOptions{})
+	sc.tiles.setTile(0, 2, 3, tileAsteroid)
+	below := &sprite{x: 20, y: 40, width: 16, height: 16}
+	above := &sprite{x: 30, y: 50, width: 16, height: 16}
+	seam := &sprite{x: 1272, y: 100, width: 16, height: 16}
+	sc.sprites = []*sprite{below, above, seam}
+
+	if h := sc.hitTest(40, 52); h.sprite != above || h.col != 2 || h.row != 3 ||
+		h.layer != 0 || h.gid != tileAsteroid {
+		t.Errorf("wrong hit over overlapping sprites: %v", h)
+	}
+	if h := sc.hitTest(22, 42); h.sprite != below {
+		t.Errorf("wrong hit over bottom sprite: %v", h)
+	}
+	if h := sc.hitTest(4, 104); h.sprite != seam {
+		t.Errorf("wrong hit over sprite across the seam: %v", h)
+	}
+	if h := sc.hitTest(200, 200); h.sprite != nil || h.gid != tileSpace {
+		t.Errorf("wrong hit over space: %v", h)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/ebitengine/debugui"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// editorTool is the action of the left mouse button in the editor.
+type editorTool int
+
+const (
+	editorPaint editorTool = iota
+	editorErase
+	editorFill
+	editorRect
+)
+
+var editorToolNames = []string{"Paint", "Erase", "Fill", "Rect"}
+
+const (
+	// editorFillLimit bounds flood fills, since generated worlds are huge.
+	editorFillLimit = 1 << 14
+
+	// editorPaletteMargin is the gap between the palette and the screen edges.
+	editorPaletteMargin = 8
+)
+
+// tileEdit is a change of a tile, kept for undo and redo.
+type tileEdit struct {
+	layer, col, row int
+	before, after   int
+}
+
+// editor changes the tilemap of the current scene with the mouse.
+// It is toggled with the E key and its tools live in the debugui window.
+// The palette with the tiles of a tileset is drawn at the bottom-right
+// corner of the screen.
+type editor struct {
+	active  bool
+	sc      *scene // scene being edited, the history is cleared when it changes
+	tool    editorTool
+	layer   int
+	gid     int // selected global tile ID
+	palette int // index of the tileset shown in the palette
+	status  string
+
+	stroke    []tileEdit // edits of the current mouse drag
+	undoStack [][]tileEdit
+	redoStack [][]tileEdit
+
+	dragging           bool
+	rectCol, rectRow   int // rect tool start cell
+	mouseCol, mouseRow int // cell under the mouse
+	mouseInside        bool
+}
+
+func newEditor() *editor {
+	return &editor{gid: tileSpace}
+}
+
+// toggle enters or leaves the editor mode.
+func (e *editor) toggle() {
+	e.active = !e.active
+	e.finishStroke()
+	log.Printf("Editor: %t", e.active)
+}
+
+// update handles the editor input for the scene.
+// uiCapturing tells whether the debugui window is using the mouse.
+func (e *editor) update(sc *scene, mouseX, mouseY int, uiCapturing bool) {
+	if sc != e.sc {
+		e.sc = sc
+		e.undoStack = nil
+		e.redoStack = nil
+		e.stroke = nil
+		e.dragging = false
+		e.layer = min(e.layer, len(sc.tiles.layerInfo)-1)
+		e.palette = 0
+	}
+
+	ts := sc.tiles
+
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	switch {
+	case ctrl && shift && inpututil.IsKeyJustPressed(ebiten.KeyZ),
+		ctrl && inpututil.IsKeyJustPressed(ebiten.KeyY):
+		e.redo()
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyZ):
+		e.undo()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		e.gid = max(e.gid-1, 1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		e.gid++
+	}
+
+	e.mouseCol, e.mouseRow, e.mouseInside = sc.cam.screenToTile(float64(mouseX), float64(mouseY))
+
+	if uiCapturing {
+		e.finishStroke()
+		return
+	}
+
+	pressed := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	justPressed := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+
+	// palette picks the tile
+	if r, set := e.paletteRect(sc); set != nil && image.Pt(mouseX, mouseY).In(r) {
+		if justPressed {
+			index := e.paletteIndex(set, r, mouseX, mouseY)
+			if index >= 0 {
+				e.gid = set.firstGID + index
+			}
+		}
+		if !pressed {
+			e.finishStroke()
+		}
+		return
+	}
+
+	// right button picks the tile under the mouse
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) && e.mouseInside {
+		if gid := ts.tileAt(e.layer, e.mouseCol, e.mouseRow); gid != tileEmpty {
+			e.gid = gid
+		}
+	}
+
+	switch e.tool {
+	case editorPaint, editorErase:
+		if pressed && e.mouseInside {
+			gid := e.gid
+			if e.tool == editorErase {
+				gid = tileEmpty
+				if rules := ts.terrain; rules != nil && e.layer == 0 {
+					// erased terrain leaves the background, with borders around it
+					gid = rules.background
+				}
+			}
+			e.set(e.mouseCol, e.mouseRow, gid)
+		}
+	case editorFill:
+		if justPressed && e.mouseInside {
+			e.fill(e.mouseCol, e.mouseRow, sc.cam.cyclic)
+		}
+	case editorRect:
+		if justPressed && e.mouseInside {
+			e.dragging = true
+			e.rectCol, e.rectRow = e.mouseCol, e.mouseRow
+		}
+		if !pressed && e.dragging && e.mouseInside {
+			c0, c1 := min(e.rectCol, e.mouseCol), max(e.rectCol, e.mouseCol)
+			r0, r1 := min(e.rectRow, e.mouseRow), max(e.rectRow, e.mouseRow)
+			for row := r0; row <= r1; row++ {
+				for col := c0; col <= c1; col++ {
+					e.set(col, row, e.gid)
+				}
+			}
+		}
+	}
+
+	if !pressed {
+		e.dragging = false
+		e.finishStroke()
+	}
+}
+
+// set changes a tile of the selected layer, recording it in the stroke.
+// Terrain tiles are autotiled, along with their neighbors.
+func (e *editor) set(col, row, gid int) {
+	rules := e.terrainRules(gid)
+	if rules == nil {
+		e.setRaw(col, row, gid)
+		return
+	}
+	on := gid != rules.background
+	if on && rules.variants[gid] {
+		// kept by the autotiling where fully inside the terrain
+		e.setRaw(col, row, gid)
+	}
+	edits := e.sc.tiles.paintTerrain(e.layer, col, row, rules, on, e.sc.cam.cyclic)
+	e.stroke = append(e.stroke, edits...)
+}
+
+// terrainRules returns the autotile rules of the tile on the selected
+// layer, or nil if it is not a terrain tile.
+func (e *editor) terrainRules(gid int) *autotileRules {
+	rules := e.sc.tiles.terrain
+	if rules == nil || e.layer != 0 || (gid != rules.background && !rules.member(gid)) {
+		return nil
+	}
+	return rules
+}
+
+// setRaw changes a tile of the selected layer as it is, recording it in
+// the stroke.
+func (e *editor) setRaw(col, row, gid int) {
+	ts := e.sc.tiles
+	before := ts.tileAt(e.layer, col, row)
+	if before == gid {
+		return
+	}
+	ts.setTile(e.layer, col, row, gid)
+	e.stroke = append(e.stroke, tileEdit{
+		layer: e.layer, col: col, row: row, before: before, after: gid,
+	})
+}
+
+// fill replaces the area of equal tiles around col,row with the selected tile.
+// If wrap is true, the area extends across the tilemap edges.
+// Filling terrain, the area is the region of terrain or background tiles,
+// whatever the borders drawn by the autotiling.
+func (e *editor) fill(col, row int, wrap bool) {
+	ts := e.sc.tiles
+	target := ts.tileAt(e.layer, col, row)
+	if target == e.gid {
+		return
+	}
+	same := func(gid int) bool { return gid == target }
+	if rules := e.terrainRules(e.gid); rules != nil && e.terrainRules(target) != nil {
+		inTerrain := rules.member(target)
+		if inTerrain == rules.member(e.gid) && !rules.variants[e.gid] {
+			return // already terrain, or already background
+		}
+		same = func(gid int) bool {
+			return rules.member(gid) == inTerrain && (inTerrain || gid == rules.background)
+		}
+	}
+
+	queue := []image.Point{{col, row}}
+	seen := map[image.Point]bool{{col, row}: true}
+	for len(queue) > 0 && len(seen) <= editorFillLimit {
+		p := queue[0]
+		queue = queue[1:]
+		e.set(p.X, p.Y, e.gid)
+		for _, d := range []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			c, r, inside := ts.wrapCell(p.X+d.X, p.Y+d.Y, wrap)
+			n := image.Pt(c, r)
+			if !inside || seen[n] || !same(ts.tileAt(e.layer, c, r)) {
+				continue
+			}
+			seen[n] = true
+			queue = append(queue, n)
+		}
+	}
+	if len(queue) > 0 {
+		e.status = fmt.Sprintf("fill stopped at %d tiles", editorFillLimit)
+	}
+	e.finishStroke()
+}
+
+// finishStroke records the edits of the current stroke as one undo step.
+func (e *editor) finishStroke() {
+	if len(e.stroke) == 0 {
+		return
+	}
+	e.undoStack = append(e.undoStack, e.stroke)
+	e.redoStack = nil
+	e.stroke = nil
+}
+
+func (e *editor) undo() {
+	e.finishStroke()
+	if len(e.undoStack) == 0 {
+		return
+	}
+	last := e.undoStack[len(e.undoStack)-1]
+	e.undoStack = e.undoStack[:len(e.undoStack)-1]
+	for i := len(last) - 1; i >= 0; i-- {
+		ed := last[i]
+		e.sc.tiles.setTile(ed.layer, ed.col, ed.row, ed.before)
+	}
+	e.redoStack = append(e.redoStack, last)
+}
+
+func (e *editor) redo() {
+	e.finishStroke()
+	if len(e.redoStack) == 0 {
+		return
+	}
+	last := e.redoStack[len(e.redoStack)-1]
+	e.redoStack = e.redoStack[:len(e.redoStack)-1]
+	for _, ed := range last {
+		e.sc.tiles.setTile(ed.layer, ed.col, ed.row, ed.after)
+	}
+	e.undoStack = append(e.undoStack, last)
+}
+
+// save writes the tilemap of the edited scene to its map file.
+func (e *editor) save() {
+	ts := e.sc.tiles
+	if ts.generated {
+		e.status = "generated map, not saved"
+		return
+	}
+	if ts.mapFile == "" {
+		e.status = "map has no file to save to"
+		return
+	}
+	// maps are always saved as TMX
+	filename := strings.TrimSuffix(ts.mapFile, path.Ext(ts.mapFile)) + ".tmx"
+	data, err := ts.marshalTMX()
+	if err == nil {
+		err = saveAsset(filename, data)
+	}
+	if err != nil {
+		e.status = fmt.Sprintf("save error: %v", err)
+		log.Printf("editor: %s", e.status)
+		return
+	}
+	e.status = "saved " + filename
+	log.Printf("editor: %s", e.status)
+}
+
+// window fills the debugui window with the editor controls.
+func (e *editor) window(ctx *debugui.Context) {
+	if e.sc == nil {
+		return
+	}
+	ts := e.sc.tiles
+
+	ctx.Text(fmt.Sprintf("tile: %d at %d,%d", e.gid, e.mouseCol, e.mouseRow))
+
+	ctx.SetGridLayout([]int{-1, -1, -1, -1}, nil)
+	for i, name := range editorToolNames {
+		if editorTool(i) == e.tool {
+			name = "[" + name + "]"
+		}
+		ctx.IDScope(editorToolNames[i], func() {
+			ctx.Button(name).On(func() {
+				e.tool = editorTool(i)
+			})
+		})
+	}
+
+	ctx.SetGridLayout([]int{-1, -2}, nil)
+	ctx.Text("layer")
+	names := make([]string, len(ts.layerInfo))
+	for i, info := range ts.layerInfo {
+		names[i] = info.name
+	}
+	ctx.Dropdown(&e.layer, names)
+	ctx.Text("tile")
+	ctx.NumberField(&e.gid, 1)
+
+	ctx.SetGridLayout([]int{-1, -1, -1, -1}, nil)
+	ctx.Button("Undo").On(e.undo)
+	ctx.Button("Redo").On(e.redo)
+	ctx.Button("Tileset").On(func() {
+		e.palette = (e.palette + 1) % len(ts.tilesets.tilesets)
+	})
+	ctx.Button("Save").On(e.save)
+
+	ctx.SetGridLayout(nil, nil)
+	ctx.Text(e.status)
+}
+
+// paletteRect returns the screen rectangle of the palette and its tileset.
+// Large tilesets are scaled down to fit half of the screen.
+func (e *editor) paletteRect(sc *scene) (image.Rectangle, *tileset) {
+	sets := sc.tiles.tilesets.tilesets
+	if len(sets) == 0 {
+		return image.Rectangle{}, nil
+	}
+	set := sets[min(e.palette, len(sets)-1)]
+	b := set.image.Bounds()
+	scale := min(1, float64(sc.g.screenWidth)/2/float64(b.Dx()),
+		float64(sc.g.screenHeight)/2/float64(b.Dy()))
+	w := int(float64(b.Dx()) * scale)
+	h := int(float64(b.Dy()) * scale)
+	x := sc.g.screenWidth - w - editorPaletteMargin
+	y := sc.g.screenHeight - h - editorPaletteMargin
+	return image.Rect(x, y, x+w, y+h), set
+}
+
+// paletteIndex returns the tile index at screen position x,y of the
+// palette r, or -1 if there is no tile there.
+func (e *editor) paletteIndex(set *tileset, r image.Rectangle, x, y int) int {
+	b := set.image.Bounds()
+	px := (x - r.Min.X) * b.Dx() / r.Dx()
+	py := (y - r.Min.Y) * b.Dy() / r.Dy()
+	col := (px - set.margin) / (set.tileWidth + set.spacing)
+	row := (py - set.margin) / (set.tileHeight + set.spacing)
+	if col < 0 || col >= set.columns || row < 0 {
+		return -1
+	}
+	index := row*set.columns + col
+	if index >= set.tileCount {
+		return -1
+	}
+	return index
+}
+
+// draw shows the palette, the selected tile and the cell under the mouse.
+func (e *editor) draw(screen *ebiten.Image, sc *scene) {
+	colorYellow := color.RGBA{0xff, 0xff, 0, 0xff}
+	colorCyan := color.RGBA{0, 0xff, 0xff, 0xff}
+
+	r, set := e.paletteRect(sc)
+	if set != nil {
+		b := set.image.Bounds()
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(float64(r.Dx())/float64(b.Dx()), float64(r.Dy())/float64(b.Dy()))
+		op.GeoM.Translate(float64(r.Min.X), float64(r.Min.Y))
+		screen.SubImage(r).(*ebiten.Image).Fill(color.RGBA{0, 0, 0, 0xc0})
+		screen.DrawImage(set.image, op)
+
+		if index := e.gid - set.firstGID; index >= 0 && index < set.tileCount {
+			t := set.tileRect(index)
+			sx := float64(r.Dx()) / float64(b.Dx())
+			sy := float64(r.Dy()) / float64(b.Dy())
+			drawDebugRect(screen,
+				float32(float64(r.Min.X)+float64(t.Min.X)*sx),
+				float32(float64(r.Min.Y)+float64(t.Min.Y)*sy),
+				float32(float64(r.Min.X)+float64(t.Max.X)*sx),
+				float32(float64(r.Min.Y)+float64(t.Max.Y)*sy), colorYellow)
+		}
+	}
+
+	if !e.mouseInside {
+		return
+	}
+	c0, r0, c1, r1 := e.mouseCol, e.mouseRow, e.mouseCol, e.mouseRow
+	if e.dragging {
+		c0, c1 = min(e.rectCol, e.mouseCol), max(e.rectCol, e.mouseCol)
+		r0, r1 = min(e.rectRow, e.mouseRow), max(e.rectRow, e.mouseRow)
+	}
+	size := float64(sc.tiles.tileSize) * sc.cam.zoom
+	x0, y0 := sc.cam.tileToScreen(c0, r0)
+	x1, y1 := x0+float64(c1-c0+1)*size, y0+float64(r1-r0+1)*size
+	drawDebugRect(screen, float32(x0), float32(y0), float32(x1), float32(y1), colorCyan)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+)
+
+func newTestEditor() *editor {
+	e := newEditor()
+	e.sc = &scene{tiles: newTestTiles(40, singleTileSource(tileSpace))}
+	return e
+}
+
+// go test -count 1 -run '^TestEditorUndo$' ./...
+func TestEditorUndo(t *testing.T) {
+	e := newTestEditor()
+	ts := e.sc.tiles
+
+	// one stroke of two tiles
+	e.gid = tileStar
+	e.set(1, 1, e.gid)
+	e.set(2, 1, e.gid)
+	e.finishStroke()
+
+	// erase in another stroke
+	e.set(2, 1, tileEmpty)
+	e.finishStroke()
+
+	e.undo()
+	if got := ts.tileAt(0, 2, 1); got != tileStar {
+		t.Errorf("erase not undone: got %d", got)
+	}
+	e.undo()
+	if got := ts.tileAt(0, 1, 1); got != tileSpace {
+		t.Errorf("paint not undone: got %d", got)
+	}
+	e.redo()
+	if got := ts.tileAt(0, 1, 1); got != tileStar {
+		t.Errorf("paint not redone: got %d", got)
+	}
+
+	// a new edit clears the redo history
+	e.set(5, 5, tileVoid)
+	e.finishStroke()
+	e.redo()
+	if got := ts.tileAt(0, 2, 1); got != tileStar {
+		t.Errorf("stale redo applied: got %d", got)
+	}
+}
+
+// go test -count 1 -run '^TestEditorFill$' ./...
+func TestEditorFill(t *testing.T) {
+	e := newTestEditor()
+	ts := e.sc.tiles
+
+	// a wall of voids splits the tilemap at column 10
+	for row := range 40 {
+		e.set(10, row, tileVoid)
+	}
+	e.finishStroke()
+
+	e.gid = tileStar
+	e.fill(0, 0, false)
+	if got := ts.tileAt(0, 9, 39); got != tileStar {
+		t.Errorf("area not filled: got %d", got)
+	}
+	if got := ts.tileAt(0, 11, 0); got != tileSpace {
+		t.Errorf("fill crossed the wall: got %d", got)
+	}
+
+	e.undo()
+	if got := ts.tileAt(0, 9, 39); got != tileSpace {
+		t.Errorf("fill not undone in one step: got %d", got)
+	}
+
+	// with wrap, the fill goes around the tilemap edges
+	e.fill(0, 0, true)
+	if got := ts.tileAt(0, 11, 0); got != tileStar {
+		t.Errorf("wrapped fill did not cross the edge: got %d", got)
+	}
+}
+
+// go test -count 1 -run '^TestEditorTerrain$' ./...
+func TestEditorTerrain(t *testing.T) {
+	e := newTestEditor()
+	e.sc.cam = &camera{sc: e.sc}
+	ts := e.sc.tiles
+	ts.terrain = spaceRules
+
+	// a void painted gets borders around it
+	e.set(20, 20, tileVoid)
+	e.finishStroke()
+	if got := ts.tileAt(0, 20, 21); got != 194 {
+		t.Errorf("expected top edge below the void, got %d", got)
+	}
+
+	// the whole stroke is undone, borders included
+	e.undo()
+	for _, cell := range [][2]int{{20, 20}, {20, 21}, {19, 19}} {
+		if got := ts.tileAt(0, cell[0], cell[1]); got != tileSpace {
+			t.Errorf("cell %v not undone: got %d", cell, got)
+		}
+	}
+
+	// non-terrain tiles are set as they are
+	e.set(5, 5, tileNebula)
+	if got := ts.tileAt(0, 5, 6); got != tileSpace {
+		t.Errorf("expected no border around a raw tile, got %d", got)
+	}
+	e.finishStroke()
+
+	// a wall of voids, then filling the region at its left with void
+	// goes through the borders drawn along the wall
+	for row := range 40 {
+		e.set(10, row, tileVoid)
+	}
+	e.finishStroke()
+	e.gid = tileVoid
+	e.fill(0, 0, false)
+	if got := ts.tileAt(0, 9, 39); got != tileVoid {
+		t.Errorf("terrain region not filled: got %d", got)
+	}
+	if got := ts.tileAt(0, 11, 0); got != 218 {
+		t.Errorf("expected left edge at the right of the voids, got %d", got)
+	}
+
+	// filling the voids with space removes the borders
+	e.gid = tileSpace
+	e.fill(0, 0, false)
+	if got := ts.tileAt(0, 11, 0); got != tileSpace {
+		t.Errorf("expected plain space, got %d", got)
+	}
+}
+
+// go test -count 1 -run '^TestEditorSaveGenerated$' ./...
+func TestEditorSaveGenerated(t *testing.T) {
+	e := newTestEditor()
+	e.sc.tiles = newVoidTiles(16, 8, 8, spaceRules)
+	e.save()
+	if e.status != "generated map, not saved" {
+		t.Errorf("wrong status: %q", e.status)
+	}
+}
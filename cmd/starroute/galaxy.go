@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2/examples/resources/images"
+	"github.com/udhos/starroute/torus"
+)
+
+// galaxyLayers is the number of layers of a generated galaxy:
+// background (space, stars and voids), nebulae, and bodies
+// (asteroid belts and star clusters).
+const galaxyLayers = 3
+
+// noise scales of galaxy regions, in tiles per noise lattice cell
+const (
+	galaxyDensityScale = 64
+	galaxyNebulaScale  = 32
+	galaxyBeltScale    = 128
+)
+
+// galaxy procedurally generates a world from a seed.
+// Every tile is computed only from the seed and the tile position, so the
+// same seed always produces the same galaxy, whatever the order chunks are
+// generated or evicted.
+type galaxy struct {
+	seed            uint64
+	tileLayerXCount int
+	tileLayerYCount int
+	rules           *autotileRules // autotile the space borders
+}
+
+func newGalaxy(seed uint64, tileLayerXCount, tileLayerYCount int) *galaxy {
+	return &galaxy{
+		seed:            seed,
+		tileLayerXCount: tileLayerXCount,
+		tileLayerYCount: tileLayerYCount,
+		rules:           spaceRules,
+	}
+}
+
+// galaxyLayerNames are the names of the galaxy layers.
+var galaxyLayerNames = [galaxyLayers]string{"background", "nebulae", "bodies"}
+
+// newTiles creates the tiles of the galaxy from images.Tiles_png.
+func (gx *galaxy) newTiles(tileSize int) *tiles {
+	ts := newTiles(bytes.NewReader(images.Tiles_png), tileSize, galaxyLayers,
+		gx.tileLayerXCount, gx.tileLayerYCount, gx.source)
+	for i, name := range galaxyLayerNames {
+		ts.layerInfo[i].name = name
+	}
+	ts.setTileProps(spaceTileProps)
+	ts.generated = true
+	ts.terrain = gx.rules
+	return ts
+}
+
+// source is the chunkSource for the galaxy layers.
+// Borders between space and voids are autotiled with the galaxy rules,
+// wrapping around the world edges so cyclic cameras see seamless borders.
+func (gx *galaxy) source(col, row, cols, rows int) [][]int {
+	layers := make([][]int, galaxyLayers)
+	layers[0] = gx.rules.chunk(col, row, cols, rows, gx.inSpace)
+	for li := 1; li < galaxyLayers; li++ {
+		layers[li] = make([]int, cols*rows)
+	}
+	for y := range rows {
+		for x := range cols {
+			i := y*cols + x
+			layers[0][i], layers[1][i], layers[2][i] = gx.tile(col+x, row+y, layers[0][i])
+		}
+	}
+	return layers
+}
+
+// inSpace reports whether the tile at column col and row is space,
+// rather than an empty void. Positions wrap around the world edges.
+func (gx *galaxy) inSpace(col, row int) bool {
+	col = torus.WrapInt(col, gx.tileLayerXCount)
+	row = torus.WrapInt(row, gx.tileLayerYCount)
+	return gx.noise(1, col, row, galaxyDensityScale) >= 0.35
+}
+
+// tile returns the global tile IDs of the galaxy layers at column col and
+// row, given the autotiled background.
+func (gx *galaxy) tile(col, row, autotiled int) (background, nebula, body int) {
+	background = autotiled
+	if background == tileVoid {
+		return
+	}
+
+	density := gx.noise(1, col, row, galaxyDensityScale)
+	chance := hash2Float(gx.seed, col, row)
+
+	// more stars as density grows, away from void borders
+	if background == tileSpace && chance < density*density/8 {
+		background = tileStar
+	}
+
+	if gx.noise(2, col, row, galaxyNebulaScale) > 0.6 {
+		nebula = tileNebula
+	}
+
+	// asteroid belts follow a contour line of the belt noise
+	belt := gx.noise(3, col, row, galaxyBeltScale)
+	switch {
+	case math.Abs(belt-0.5) < 0.015 && chance < 0.5:
+		body = tileAsteroid
+	case density > 0.7 && chance > 0.8:
+		body = tileStarCluster
+	}
+
+	return
+}
+
+// noise returns fractal noise for the tile at column col and row.
+// salt picks independent noise for each kind of region.
+// The noise tiles seamlessly across the world edges when the world size
+// is a multiple of the scale, so cyclic cameras do not see a seam.
+func (gx *galaxy) noise(salt uint64, col, row, scale int) float64 {
+	var periodX, periodY int
+	if gx.tileLayerXCount%scale == 0 {
+		periodX = gx.tileLayerXCount / scale
+	}
+	if gx.tileLayerYCount%scale == 0 {
+		periodY = gx.tileLayerYCount / scale
+	}
+	const octaves = 4
+	return fractalNoise(splitmix64(gx.seed^salt),
+		float64(col)/float64(scale), float64(row)/float64(scale),
+		octaves, periodX, periodY)
+}
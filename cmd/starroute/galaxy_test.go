@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+type noiseTest struct {
+	name    string
+	x, y    float64
+	periodX int
+	periodY int
+}
+
+var noiseTestTable = []noiseTest{
+	{name: "origin", x: 0, y: 0},
+	{name: "lattice point", x: 3, y: 5},
+	{name: "between lattice points", x: 2.5, y: 7.25},
+	{name: "negative", x: -1.75, y: -3.5},
+	{name: "periodic", x: 1.3, y: 2.6, periodX: 4, periodY: 8},
+}
+
+// go test -count 1 -run '^TestNoise$' ./...
+func TestNoise(t *testing.T) {
+	const seed = 42
+	for i, data := range noiseTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(noiseTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			v := valueNoise(seed, data.x, data.y, data.periodX, data.periodY)
+			if v < 0 || v >= 1 {
+				t.Errorf("noise out of range: %v", v)
+			}
+			if again := valueNoise(seed, data.x, data.y, data.periodX, data.periodY); again != v {
+				t.Errorf("noise not deterministic: %v then %v", v, again)
+			}
+			if other := valueNoise(seed+1, data.x, data.y, data.periodX, data.periodY); other == v {
+				t.Errorf("noise should change with seed: %v", v)
+			}
+			if data.periodX > 0 {
+				shifted := valueNoise(seed, data.x+float64(data.periodX),
+					data.y-float64(data.periodY), data.periodX, data.periodY)
+				if math.Abs(shifted-v) > 1e-9 {
+					t.Errorf("noise not periodic: %v then %v", v, shifted)
+				}
+			}
+			f := fractalNoise(seed, data.x, data.y, 4, data.periodX, data.periodY)
+			if f < 0 || f >= 1 {
+				t.Errorf("fractal noise out of range: %v", f)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestGalaxyDeterministic$' ./...
+func TestGalaxyDeterministic(t *testing.T) {
+	const size = 256
+
+	whole := newGalaxy(7, size, size).source(32, 32, 32, 32)
+
+	// same region generated in four chunks by another galaxy with the same seed
+	gx := newGalaxy(7, size, size)
+	for _, pos := range [][2]int{{0, 0}, {16, 0}, {0, 16}, {16, 16}} {
+		chunk := gx.source(32+pos[0], 32+pos[1], 16, 16)
+		for li := range chunk {
+			for y := range 16 {
+				got := chunk[li][y*16 : y*16+16]
+				i := (pos[1]+y)*32 + pos[0]
+				if !slices.Equal(got, whole[li][i:i+16]) {
+					t.Fatalf("layer %d row %d differs: %v vs %v", li, y, got, whole[li][i:i+16])
+				}
+			}
+		}
+	}
+
+	other := newGalaxy(8, size, size).source(32, 32, 32, 32)
+	if slices.Equal(whole[0], other[0]) {
+		t.Errorf("different seeds produced the same galaxy")
+	}
+}
+
+// go test -count 1 -run '^TestGalaxyRegions$' ./...
+func TestGalaxyRegions(t *testing.T) {
+	const size = 512
+	layers := newGalaxy(3, size, size).source(0, 0, size, size)
+
+	count := map[int]int{}
+	for li := range layers {
+		for _, gid := range layers[li] {
+			count[gid]++
+		}
+	}
+
+	for _, gid := range []int{tileVoid, tileSpace, tileStar, tileNebula, tileAsteroid, tileStarCluster} {
+		if count[gid] == 0 {
+			t.Errorf("galaxy has no tile %d: %v", gid, count)
+		}
+	}
+}
+
+// go test -count 1 -run '^TestGalaxyWang16$' ./...
+func TestGalaxyWang16(t *testing.T) {
+	const size = 256
+	gx := newGalaxy(3, size, size)
+	gx.rules = spaceRulesWang16
+	background := gx.source(0, 0, size, size)[0]
+
+	var edges int
+	for _, gid := range background {
+		switch gid {
+		case 197, 198, 222, 223:
+			t.Fatalf("wang16 galaxy has inner corner tile %d", gid)
+		case 194, 221, 269, 218:
+			edges++
+		}
+	}
+	if edges == 0 {
+		t.Errorf("wang16 galaxy has no edge tiles")
+	}
+}
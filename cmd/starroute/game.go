@@ -14,7 +14,6 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/examples/resources/images"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
@@ -33,11 +32,14 @@ type game struct {
 	pause bool
 	debug bool
 
-	scenes           []*scene
-	sceneStart       int
-	sceneCurrent     int
-	sceneResume      int
-	sceneUpdateInput func(sc *scene)
+	scenes       []*scene
+	sceneCurrent int // the scene being played, at the bottom of the stack
+	sceneMenu    int // the start menu, pushed by Esc
+	scenePause   int // the pause overlay, pushed by P, -1 if none
+
+	// stack is the scene being played, with overlays on top.
+	// The top scene gets the input.
+	stack []*scene
 
 	defaultScreenWidth  int
 	defaultScreenHeight int
@@ -58,40 +60,24 @@ type game struct {
 	mplusFaceSource *text.GoTextFaceSource
 	//uiCoord         string
 
-	debugui debugui.DebugUI
-}
+	debugui     debugui.DebugUI
+	uiCapturing bool // debugui is using the mouse or keyboard
 
-func newGame(defaultScreenWidth, defaultScreenHeight int) *game {
+	editor *editor
 
-	//
-	// Load an image from the embedded image data.
-	//
+	transition *transition // running scene switch, nil if none
 
-	var ebitenImage *ebiten.Image
-	var rotationScene1Sprite2 float64
+	edgeScroll  bool // the cursor at the screen border scrolls the camera
+	showMinimap bool
+}
 
-	if false {
-		const scaleAlpha = 0.8
-		rotationScene1Sprite2 = oneEighth
-		ebitenImage = createImage(bytes.NewReader(images.Ebiten_png), scaleAlpha)
-	} else {
-		const scaleAlpha = 1
-		rotationScene1Sprite2 = -oneQuarter
-		ebitenImage = createImage(bytes.NewReader(mustLoadAsset("newGame", "body_01.png")), scaleAlpha)
-	}
+func newGame(defaultScreenWidth, defaultScreenHeight int, seed uint64) *game {
 
 	mplusFaceSource, err := text.NewGoTextFaceSource(bytes.NewReader(fonts.MPlus1pRegular_ttf))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	const (
-		// FIXME: these should come from tilemap data
-		tileSize             = 16
-		tileLayerScreenWidth = 240
-		tileLayerXCount      = 15
-	)
-
 	g := &game{
 		debug: true,
 
@@ -102,11 +88,12 @@ func newGame(defaultScreenWidth, defaultScreenHeight int) *game {
 		screenWidth:  defaultScreenWidth,
 		screenHeight: defaultScreenHeight,
 
-		sceneStart:   0,
-		sceneCurrent: 0,
-
 		mplusFaceSource: mplusFaceSource,
 		//uiCoord:         "? ?",
+
+		editor: newEditor(),
+
+		showMinimap: true,
 	}
 
 	// This adds the root container to the UI, so that it will be rendered.
@@ -114,78 +101,27 @@ func newGame(defaultScreenWidth, defaultScreenHeight int) *game {
 
 	audioContext := audio.NewContext(music.SampleRate)
 
-	ts := newTiles(bytes.NewReader(images.Tiles_png), tileSize, sampleLayers, tileLayerXCount)
-
-	const (
-		cyclicCamera     = false
-		centralizeCamera = false
-		showCoord        = true
-	)
-
-	// scene0: start screen
-	var scene0 *scene
-	{
-		const tileEdgeCount = 120                                      // 1920x1920
-		layers := [][]int{generateLayerSingleTile(tileEdgeCount, 247)} // 247=dirty
-		ts := newTiles(bytes.NewReader(images.Tiles_png), tileSize, layers, tileEdgeCount)
-		scene0 = newScene(g, ts, sceneTrack1, audioContext, cyclicCamera,
-			centralizeCamera, false,
-			sceneOptions{banner: "press: [p]lay or [q]uit"})
+	defs, err := loadSceneDefs(os.DirFS("assets"), sceneDir)
+	if err != nil {
+		log.Fatalf("newGame: %v", err)
 	}
-
-	// scene1: hard-coded tilemap
-	scene1 := newScene(g, ts, sceneTrack1, audioContext, cyclicCamera,
-		centralizeCamera, showCoord, sceneOptions{})
-	scene1.addSprite(50, 50, 0, ebitenImage)
-	scene1.addSprite(100, 100, rotationScene1Sprite2, ebitenImage)
-
-	// scene2: hard-coded tilemap
-	scene2 := newScene(g, ts, sceneTrack2, audioContext, cyclicCamera,
-		centralizeCamera, showCoord, sceneOptions{})
-	scene2.addSprite(150, 150, 0, ebitenImage)
-	scene2.addSprite(200, 200, oneQuarter, ebitenImage)
-
-	// scene3: random tilemap
-
-	var scene3 *scene
-	{
-		const tileEdgeCount = 120 // 1920x1920
-		layers := [][]int{generateLayer(tileEdgeCount)}
-		ts3 := newTiles(bytes.NewReader(images.Tiles_png), tileSize, layers, tileEdgeCount)
-
-		scene3 = newScene(g, ts3, sceneTrack3, audioContext, cyclicCamera,
-			centralizeCamera, showCoord, sceneOptions{})
-
-		// add a sprite close to top-left corner
-		scene3.addSprite(50, 50, -oneQuarter, ebitenImage)
-
-		// add a sprite at center of tilemap
-		x := scene3.tiles.tilePixelWidth() / 2
-		y := scene3.tiles.tilePixelHeight() / 2
-		scene3.addSprite(float64(x), float64(y), -oneQuarter, ebitenImage)
+	g.sceneMenu, g.sceneCurrent, err = sceneOrder(defs)
+	if err != nil {
+		log.Fatalf("newGame: %v", err)
 	}
-
-	// scene4: first scene
-	var scene4 *scene
-	{
-		const tileEdgeCount = 120 // 1920x1920
-		layers := [][]int{generateLayer(tileEdgeCount)}
-		ts := newTiles(bytes.NewReader(images.Tiles_png), tileSize, layers, tileEdgeCount)
-
-		scene4 = newScene(g, ts, sceneTrack1, audioContext, true, true,
-			showCoord, sceneOptions{})
-
-		// add a sprite at center of tilemap
-		x := scene4.tiles.tilePixelWidth() / 2
-		y := scene4.tiles.tilePixelHeight() / 2
-		scene4.addSprite(float64(x), float64(y), -oneQuarter, ebitenImage)
+	g.scenePause = findScene(defs, "pause")
+	g.scenes, err = g.newScenes(defs, audioContext, seed)
+	if err != nil {
+		log.Fatalf("newGame: %v", err)
 	}
+	log.Printf("Scenes: %d, start: %s, first: %s", len(g.scenes),
+		g.scenes[g.sceneMenu].name, g.scenes[g.sceneCurrent].name)
 
-	g.scenes = []*scene{scene0, scene1, scene2, scene3, scene4}
-
-	g.sceneCurrent = 4 // first scene after start screen
-
-	g.switchScene(g.sceneStart)
+	// the start menu shows over the first scene, so play resumes there
+	first := g.scenes[g.sceneCurrent]
+	g.stack = []*scene{first}
+	first.behavior.enter(first)
+	g.pushScene(g.scenes[g.sceneMenu])
 
 	// See comment in game.Layout method.
 	log.Printf("Game screen size: %dx%d", g.screenWidth, g.screenHeight)
@@ -193,31 +129,42 @@ func newGame(defaultScreenWidth, defaultScreenHeight int) *game {
 	return g
 }
 
+// switchScene plays the scene newScene, replacing the whole stack.
 func (g *game) switchScene(newScene int) {
 
 	log.Printf("switch scene to: %d", newScene)
 
-	g.getCurrentScene().musicStop()
+	from := g.getCurrentScene()
+	to := g.scenes[newScene]
+	var stacked bool // the new scene was under an overlay
+	for _, sc := range g.stack {
+		if sc == to {
+			stacked = true
+			continue
+		}
+		if sc != from {
+			sc.musicStop()
+		}
+		sc.behavior.exit(sc)
+	}
 
-	if newScene == g.sceneStart {
-		// save current scene to resume
-		if g.sceneCurrent != g.sceneStart {
-			// but not if we are on start
-			g.sceneResume = g.sceneCurrent
+	g.sceneCurrent = newScene
+	g.stack = []*scene{to}
+	if stacked {
+		if from != to {
+			sceneResume(to)
 		}
-		g.sceneUpdateInput = sceneUpdateInputStart // resume or exit controls
 	} else {
-		g.sceneUpdateInput = sceneUpdateInputDefault // all controls
+		to.behavior.enter(to)
 	}
-	g.sceneCurrent = newScene
 
-	g.getCurrentScene().musicStart()
+	g.startTransition(from, to)
 }
 
 func sceneUpdateInputStart(sc *scene) {
 	g := sc.g
 	if inpututil.IsKeyJustReleased(ebiten.KeyP) {
-		g.switchScene(g.sceneResume)
+		g.popScene() // resume
 	}
 	if inpututil.IsKeyJustReleased(ebiten.KeyQ) {
 		log.Printf("Q pressed, quitting")
@@ -225,6 +172,13 @@ func sceneUpdateInputStart(sc *scene) {
 	}
 }
 
+func sceneUpdateInputPause(sc *scene) {
+	g := sc.g
+	if inpututil.IsKeyJustReleased(ebiten.KeyP) || inpututil.IsKeyJustReleased(ebiten.KeyEscape) {
+		g.popScene() // resume
+	}
+}
+
 func sceneUpdateInputDefault(sc *scene) {
 
 	g := sc.g
@@ -237,52 +191,54 @@ func sceneUpdateInputDefault(sc *scene) {
 		//p := keys[len(keys)-1]
 
 		switch p {
-		case ebiten.KeyUp:
-			g.getCurrentScene().cam.stepUp()
-			continue
-		case ebiten.KeyDown:
-			g.getCurrentScene().cam.stepDown()
-			continue
-		case ebiten.KeyLeft:
-			g.getCurrentScene().cam.stepRight()
-			continue
-		case ebiten.KeyRight:
-			g.getCurrentScene().cam.stepLeft()
-			continue
 		case ebiten.KeyEscape:
-			log.Printf("ESC pressed, switching to start screen")
-			g.switchScene(g.sceneStart)
-			continue
+			log.Printf("ESC pressed, showing start screen")
+			g.pushScene(g.scenes[g.sceneMenu])
+			return
 		}
 
-		zero := p == ebiten.Key0
-		plus := p == ebiten.KeyEqual
-		minus := p == ebiten.KeyMinus
-		if zero {
-			g.screenWidth = g.defaultScreenWidth
-			g.screenHeight = g.defaultScreenHeight
+		// zoom anchored at the center of the main camera
+		r := sc.cam.screenRect()
+		centerX, centerY := (r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2
+		switch p {
+		case ebiten.Key0:
+			sc.cam.setZoom(1, centerX, centerY)
+		case ebiten.KeyEqual:
+			sc.cam.zoomBy(camZoomKeyStep, centerX, centerY)
+		case ebiten.KeyMinus:
+			sc.cam.zoomBy(1/camZoomKeyStep, centerX, centerY)
 		}
-		if plus {
-			if g.screenWidth < 10000 {
-				g.screenWidth += g.screenWidth / 10
-			}
-			if g.screenHeight < 10000 {
-				g.screenHeight += g.screenHeight / 10
-			}
+	}
+
+	if sc.player != nil {
+		var ax, ay float64
+		if ebiten.IsKeyPressed(ebiten.KeyW) {
+			ay--
 		}
-		if minus {
-			if g.screenWidth > 10 {
-				g.screenWidth -= g.screenWidth / 10
-			}
-			if g.screenHeight > 10 {
-				g.screenHeight -= g.screenHeight / 10
-			}
+		if ebiten.IsKeyPressed(ebiten.KeyS) {
+			ay++
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyA) {
+			ax--
 		}
-		if zero || plus || minus {
-			log.Printf("Game screen size: %dx%d", g.screenWidth, g.screenHeight)
+		if ebiten.IsKeyPressed(ebiten.KeyD) {
+			ax++
 		}
+		sc.player.steer(ax, ay, tickSeconds())
+		sc.player.animateSteering(ax != 0 || ay != 0)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyX) && sc.player != nil && sc.player.anim != nil {
+		sc.player.anim.play("explode")
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyF) && sc.player != nil {
+		// toggle camera following the player
+		if sc.cam.target == nil {
+			sc.cam.setTarget(sc.player)
+		} else {
+			sc.cam.setTarget(nil)
+		}
+		log.Printf("Camera follow: %t", sc.cam.target != nil)
 	}
-
 	if inpututil.IsKeyJustReleased(ebiten.KeyI) {
 		sc.cam.centralize()
 		log.Print("Cam centralized")
@@ -292,20 +248,72 @@ func sceneUpdateInputDefault(sc *scene) {
 		log.Printf("Debug: %t", g.debug)
 	}
 	if inpututil.IsKeyJustReleased(ebiten.KeyP) {
-		g.pause = !g.pause
-		log.Printf("Pause: %t", g.pause)
+		if g.pause || g.scenePause < 0 {
+			g.pause = !g.pause
+			log.Printf("Pause: %t", g.pause)
+		} else {
+			g.pushScene(g.scenes[g.scenePause])
+			return
+		}
 	}
 	if inpututil.IsKeyJustReleased(ebiten.KeyBackspace) {
-		next := (g.sceneCurrent + 1) % len(g.scenes)
-		if next == g.sceneStart {
+		// next scene to play, skipping overlays
+		next := g.sceneCurrent
+		for {
 			next = (next + 1) % len(g.scenes)
+			if g.scenes[next].input == "play" {
+				break
+			}
 		}
 		g.switchScene(next)
 	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyC) {
+		ts := g.getCurrentScene().tiles
+		ts.noChunkCache = !ts.noChunkCache
+		log.Printf("Tile chunk cache: %t", !ts.noChunkCache)
+	}
+	//
+	// camera effects
+	//
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		sc.cam.shake(0.5)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		sc.cam.flash(color.RGBA{0xff, 0xff, 0xff, 0xff}, 0.8, 0.4)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyH) {
+		// fade out or back in
+		to := 1.0
+		if sc.cam.fx.fade.to > 0 {
+			to = 0
+		}
+		sc.cam.fade(color.RGBA{0, 0, 0, 0xff}, to, 1)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyJ) && !sc.cam.scripted() {
+		// tour to the center of the tilemap and back
+		w, h := sc.tiles.tilePixelDimensions()
+		sc.cam.panTo(float64(w)/2, float64(h)/2, 2, easeInOutCubic)
+		sc.cam.hold(1)
+		sc.cam.panBack(2, easeInOutCubic)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyN) {
+		g.edgeScroll = !g.edgeScroll
+		log.Printf("Edge scrolling: %t", g.edgeScroll)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyM) {
+		g.showMinimap = !g.showMinimap
+		log.Printf("Minimap: %t", g.showMinimap)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyV) {
+		sc.setViewMode((sc.viewMode + 1) % viewModes)
+		log.Printf("View mode: %d cameras", len(sc.cams))
+	}
 	if inpututil.IsKeyJustReleased(ebiten.KeyPeriod) {
 		// toggle camera cyclic
 		cam := g.getCurrentScene().cam
 		cam.cyclic = !cam.cyclic
+		r := cam.screenRect()
+		cam.setZoom(cam.zoom, (r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2) // cyclic limits zoom out
 		log.Printf("Camera cyclic: %t", cam.cyclic)
 	}
 	/*
@@ -319,8 +327,37 @@ func sceneUpdateInputDefault(sc *scene) {
 		}
 	*/
 
+	if inpututil.IsKeyJustReleased(ebiten.KeyE) {
+		g.editor.toggle()
+	}
+
 	g.mouseX, g.mouseY = ebiten.CursorPosition()
 
+	// mouse wheel zoom anchored at the cursor, of the camera under it
+	if _, wheelY := ebiten.Wheel(); wheelY != 0 && !g.uiCapturing {
+		cam := sc.cameraAt(float64(g.mouseX), float64(g.mouseY))
+		cam.zoomBy(math.Pow(camZoomStep, wheelY), g.mouseX, g.mouseY)
+	}
+
+	sceneUpdateCamera(sc)
+
+	// clicking the minimap moves the camera there
+	overMinimap := sc.minimapShown() && sc.minimap.contains(g.mouseX, g.mouseY)
+	if overMinimap && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && !g.uiCapturing {
+		sc.minimap.click(g.mouseX, g.mouseY)
+	}
+
+	if g.editor.active {
+		g.editor.update(sc, g.mouseX, g.mouseY, g.uiCapturing || overMinimap)
+	} else if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && !g.uiCapturing && !overMinimap {
+		// clicking a sprite makes the camera follow it
+		h := sc.hitTest(float64(g.mouseX), float64(g.mouseY))
+		log.Printf("Clicked: %v", h)
+		if h.sprite != nil {
+			sc.cam.setTarget(h.sprite)
+		}
+	}
+
 	//
 	// ui
 	//
@@ -342,15 +379,25 @@ func sceneUpdateInputDefault(sc *scene) {
 // default (i.e. an Ebitengine game works in 60 ticks-per-second).
 func (g *game) Update() (err error) {
 
-	g.sceneUpdateInput(g.getCurrentScene())
+	if g.transition == nil {
+		// input is locked during transitions
+		sc := g.getCurrentScene()
+		sceneInputs[sc.input](sc)
+	}
+	g.updateTransition(tickSeconds())
 
 	//g.uiCoord = g.getCurrentScene().getWorldCoordinates()
 
-	if _, e := g.debugui.Update(func(ctx *debugui.Context) error {
+	capturing, e := g.debugui.Update(func(ctx *debugui.Context) error {
 		x, y := 350, 30
 		dx := x + 320
-		dy := y + 150
+		dy := y + 220
 		ctx.Window("Debugui Window", image.Rect(x, y, dx, dy), func(_ debugui.ContainerLayout) {
+			if g.editor.active {
+				g.editor.window(ctx)
+				return
+			}
+
 			// Place all your widgets inside a ctx.Window's callback.
 			ctx.Text("test")
 
@@ -364,21 +411,87 @@ func (g *game) Update() (err error) {
 			})
 		})
 		return nil
-	}); e != nil {
+	})
+	if e != nil {
 		err = e
 	}
+	g.uiCapturing = capturing != 0
 
 	if g.pause {
 		return
 	}
 
-	g.getCurrentScene().update()
+	for _, sc := range g.activeScenes() {
+		sc.behavior.update(sc, tickSeconds())
+	}
 
 	return
 }
 
+// sceneUpdateCamera moves the main camera with the arrow keys, the cursor
+// at the screen border and by dragging the world with the mouse.
+func sceneUpdateCamera(sc *scene) {
+	g := sc.g
+	cam := sc.cam
+	dt := tickSeconds()
+	mx, my := float64(g.mouseX), float64(g.mouseY)
+
+	//
+	// drag with the middle button, or the right button outside the
+	// editor, where it picks tiles
+	//
+	dragPressed := ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) ||
+		(!g.editor.active && ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight))
+	switch {
+	case dragPressed && !cam.dragging:
+		if !g.uiCapturing && !cam.scripted() {
+			cam.setTarget(nil)
+			cam.dragStart(mx, my)
+		}
+	case dragPressed:
+		cam.dragMove(mx, my, dt)
+	case cam.dragging:
+		cam.dragEnd()
+	}
+
+	//
+	// panning
+	//
+	var panX, panY float64
+	if ebiten.IsKeyPressed(ebiten.KeyUp) {
+		panY--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyDown) {
+		panY++
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+		panX--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyRight) {
+		panX++
+	}
+	if g.edgeScroll && !g.uiCapturing && ebiten.IsFocused() {
+		edgeX, edgeY := cam.edgeDirection(mx, my)
+		panX = max(min(panX+edgeX, 1), -1)
+		panY = max(min(panY+edgeY, 1), -1)
+	}
+	if panX != 0 || panY != 0 {
+		// manual panning releases the camera from its target
+		cam.setTarget(nil)
+	}
+	cam.pan(panX, panY, dt)
+}
+
+// tickSeconds returns the duration of one game tick, so movement can be
+// expressed per second whatever the TPS.
+func tickSeconds() float64 {
+	return 1 / float64(ebiten.TPS())
+}
+
+// getCurrentScene returns the scene on top of the stack, which gets the
+// input.
 func (g *game) getCurrentScene() *scene {
-	return g.scenes[g.sceneCurrent]
+	return g.stack[len(g.stack)-1]
 }
 
 /*
@@ -409,7 +522,17 @@ func (g *game) Draw(screen *ebiten.Image) {
 
 	sc := g.getCurrentScene()
 
-	drawnTiles := sc.draw(screen, g.debug)
+	var drawnTiles int
+	if g.transition != nil {
+		g.drawTransition(screen, g.debug)
+	} else {
+		drawnTiles = g.drawStack(screen, g.debug)
+	}
+
+	editing := g.editor.active && g.editor.sc == sc
+	if editing {
+		g.editor.draw(screen, sc)
+	}
 
 	//g.ui.Draw(screen)
 
@@ -426,22 +549,31 @@ func (g *game) Draw(screen *ebiten.Image) {
 	if g.debug {
 		tileDimX, tileDimY := sc.tiles.tilePixelDimensions()
 		cam := sc.cam
-		camLastX := cam.x + g.screenWidth - 1
-		camLastY := cam.y + g.screenHeight - 1
+		viewWidth, viewHeight := cam.viewSize()
+		camX, camY, _, _ := cam.origin()
+		camLastX := camX + viewWidth - 1
+		camLastY := camY + viewHeight - 1
+		mouseWorldX, mouseWorldY := cam.screenToWorld(float64(g.mouseX), float64(g.mouseY))
+		terrain, _ := sc.tiles.propsAtPixel(int(mouseWorldX), int(mouseWorldY), cam.cyclic)
+		mouseHit := sc.hitTest(float64(g.mouseX), float64(g.mouseY))
 		ebitenutil.DebugPrint(screen,
-			fmt.Sprintf("TPS:%0.1f FPS:%0.1f tilemap:%dx%d cam:%dx%d-%dx%d camMax:%dx%d mouse:%dx%d win:%dx%d drawnTiles:%d",
+			fmt.Sprintf("TPS:%0.1f FPS:%0.1f tilemap:%dx%d cam:%dx%d-%dx%d camMax:%dx%d zoom:%.2f mouse:%dx%d world:%.0fx%.0f win:%dx%d drawnTiles:%d terrain:%v hit:%v",
 				ebiten.ActualTPS(), ebiten.ActualFPS(),
 				tileDimX, tileDimY,
-				cam.x, cam.y,
+				camX, camY,
 				camLastX, camLastY,
 				cam.maxX(), cam.maxY(),
+				cam.zoom,
 				g.mouseX, g.mouseY,
+				mouseWorldX, mouseWorldY,
 				g.windowWidth, g.windowHeight,
-				drawnTiles))
+				drawnTiles, terrain, mouseHit))
 
 		//colorBlue := color.RGBA{0, 0, 0xff, 0xff}
 		//drawDebugRect(screen, 1, 1, float32(g.screenWidth), float32(g.screenHeight), colorBlue)
+	}
 
+	if g.debug || editing {
 		g.debugui.Draw(screen)
 	}
 }
@@ -499,6 +631,12 @@ func drawDebugRect(screen *ebiten.Image, x1, y1, x2, y2 float32, borderColor col
 // If you don't have to adjust the screen size with the outside size, just
 // return a fixed size.
 func (g *game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	if outsideWidth != g.windowWidth || outsideHeight != g.windowHeight {
+		for _, sc := range g.stack {
+			sc.behavior.layout(sc, outsideWidth, outsideHeight)
+		}
+	}
+
 	// used only to debug window size
 	g.windowWidth = outsideWidth
 	g.windowHeight = outsideHeight
@@ -1,29 +1,88 @@
 package main
 
-import "math/rand/v2"
-
-func generateLayer(tileEdgeCount int) []int {
-	size := tileEdgeCount * tileEdgeCount
-	layer := make([]int, size)
-	for i := range size {
-		if randOneIn(20) {
-			layer[i] = 218
-			continue
-		}
-		layer[i] = 243
-	}
-	return layer
+import (
+	"bytes"
+
+	"github.com/hajimehoshi/ebiten/v2/examples/resources/images"
+)
+
+// global tile IDs of images.Tiles_png
+const (
+	tileAsteroid    = 205
+	tileStar        = 219
+	tileSpace       = 244
+	tileVoid        = 248 // the dirt the space border tiles blend into
+	tileNebula      = 302
+	tileStarCluster = 305
+)
+
+// spaceTileProps are the gameplay properties of the tiles of generated
+// maps. They match the properties in assets/tiles.tsx.
+var spaceTileProps = map[int]tileProps{
+	tileAsteroid:    {solid: true, mineral: "Francium-223"},
+	tileNebula:      {slow: 0.5},
+	tileStarCluster: {hazard: true},
 }
 
-func randOneIn(n int) bool {
-	return rand.IntN(n) == n-1
+// spaceRules autotiles space regions over voids with the 47-tile blob
+// rules. images.Tiles_png lacks art for some blob masks, like thin strips,
+// so those fall back to plain space. Stars are variants of plain space.
+var spaceRules = newAutotileRules(autotileBlob47, map[int]int{
+	255: tileSpace,
+	124: 194, // top edge
+	241: 221, // right edge
+	199: 269, // bottom edge
+	31:  218, // left edge
+	28:  193, // top-left corner
+	112: 196, // top-right corner
+	7:   268, // bottom-left corner
+	193: 271, // bottom-right corner
+	247: 197, // inner corner, void at bottom-right
+	223: 198, // inner corner, void at bottom-left
+	253: 222, // inner corner, void at top-right
+	127: 223, // inner corner, void at top-left
+}, tileVoid, tileSpace, tileStar)
+
+// spaceRulesWang16 is like spaceRules, with the 16-tile Wang rules that
+// look only at edge neighbors, so inner corners are not drawn.
+var spaceRulesWang16 = newAutotileRules(autotileWang16, map[int]int{
+	15: tileSpace,
+	14: 194, // top edge
+	13: 221, // right edge
+	11: 269, // bottom edge
+	7:  218, // left edge
+	6:  193, // top-left corner
+	12: 196, // top-right corner
+	3:  268, // bottom-left corner
+	9:  271, // bottom-right corner
+}, tileVoid, tileSpace, tileStar)
+
+// spaceAutotileRules are the rules for space borders, by name as in
+// scene files.
+var spaceAutotileRules = map[string]*autotileRules{
+	"blob47": spaceRules,
+	"wang16": spaceRulesWang16,
 }
 
-func generateLayerSingleTile(tileEdgeCount, index int) []int {
-	size := tileEdgeCount * tileEdgeCount
-	layer := make([]int, size)
-	for i := range size {
-		layer[i] = index
+// newVoidTiles creates a map of a single layer of width x height void
+// tiles. The void is not drawn, so a backdrop, or the scenes below an
+// overlay, show through it. Space painted in the void is autotiled by rules.
+func newVoidTiles(tileSize, width, height int, rules *autotileRules) *tiles {
+	ts := newTiles(bytes.NewReader(images.Tiles_png), tileSize, 1,
+		width, height, singleTileSource(tileVoid))
+	ts.generated = true
+	ts.terrain = rules
+	ts.seeThrough = tileVoid
+	return ts
+}
+
+// singleTileSource is a chunkSource for a single layer filled with one tile.
+func singleTileSource(gid int) chunkSource {
+	return func(_, _, cols, rows int) [][]int {
+		layer := make([]int, cols*rows)
+		for i := range layer {
+			layer[i] = gid
+		}
+		return [][]int{layer}
 	}
-	return layer
 }
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"strconv"
 	"strings"
 
@@ -20,11 +21,13 @@ func main() {
 	var screen string
 	var window string
 	var both string
+	var seed uint64
 	flag.BoolVar(&pause, "pause", false, "pause game update")
 	flag.StringVar(&resize, "resize", "on", "window resize mode: on|off|fullscreen")
 	flag.StringVar(&screen, "screen", "800x600", "game logical screen size (should be <= window size)")
 	flag.StringVar(&window, "window", "800x600", "outsize window size (should be multiple of screen size)")
 	flag.StringVar(&both, "both", "", "screen and window size")
+	flag.Uint64Var(&seed, "seed", 0, "galaxy seed, share it to play the same galaxy (0 picks a random seed)")
 	flag.Parse()
 
 	var screenWidth, screenHeight, windowWidth, windowHeight int
@@ -59,7 +62,12 @@ func main() {
 	ebiten.SetWindowSize(windowWidth, windowHeight)
 	ebiten.SetWindowResizingMode(resizeMode)
 
-	g := newGame(screenWidth, screenHeight)
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	log.Printf("Galaxy seed: %d", seed)
+
+	g := newGame(screenWidth, screenHeight, seed)
 
 	g.pause = pause
 
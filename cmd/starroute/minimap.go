@@ -0,0 +1,187 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	minimapMaxSize = 160 // longest minimap edge, in screen pixels
+	minimapMargin  = 10  // distance to the screen corner
+)
+
+// minimap shows the whole tilemap of a scene in the bottom-left screen
+// corner, with the camera rectangles and the sprites. Clicking it moves
+// the main camera there.
+type minimap struct {
+	sc      *scene
+	image   *ebiten.Image // tilemap downscaled, nil until rendered
+	version int           // tiles version rendered into image
+}
+
+func newMinimap(sc *scene) *minimap {
+	return &minimap{sc: sc}
+}
+
+// rect returns the screen rectangle of the minimap, with the aspect ratio
+// of the tilemap.
+func (m *minimap) rect() image.Rectangle {
+	ts := m.sc.tiles
+	w, h := minimapMaxSize, minimapMaxSize
+	if ts.tileLayerXCount > ts.tileLayerYCount {
+		h = max(1, minimapMaxSize*ts.tileLayerYCount/ts.tileLayerXCount)
+	} else {
+		w = max(1, minimapMaxSize*ts.tileLayerXCount/ts.tileLayerYCount)
+	}
+	bottom := m.sc.g.screenHeight - minimapMargin
+	return image.Rect(minimapMargin, bottom-h, minimapMargin+w, bottom)
+}
+
+// render draws the tilemap downscaled into the minimap image. Each
+// minimap cell samples a single tile, so huge worlds cost no more than
+// small ones, and their chunks are not loaded.
+func (m *minimap) render() {
+	ts := m.sc.tiles
+	r := m.rect()
+
+	if m.image == nil || m.image.Bounds().Size() != r.Size() {
+		if m.image != nil {
+			m.image.Deallocate()
+		}
+		m.image = ebiten.NewImage(r.Dx(), r.Dy())
+	}
+	m.image.Fill(color.RGBA{0x10, 0x10, 0x20, 0xff})
+	m.version = ts.version
+
+	cols := min(ts.tileLayerXCount, r.Dx())
+	rows := min(ts.tileLayerYCount, r.Dy())
+	cellWidth := float64(r.Dx()) / float64(cols)
+	cellHeight := float64(r.Dy()) / float64(rows)
+
+	op := &ebiten.DrawImageOptions{}
+	op.Filter = ebiten.FilterLinear // average the tile into the cell
+	for y := range rows {
+		row := y * ts.tileLayerYCount / rows
+		for x := range cols {
+			col := x * ts.tileLayerXCount / cols
+			for li, gid := range ts.peekTiles(col, row) {
+				if gid == tileEmpty || !ts.layerInfo[li].visible {
+					continue
+				}
+				set, t := ts.tilesets.resolve(gid & tileGIDMask)
+				if set == nil {
+					continue
+				}
+				op.GeoM.Reset()
+				op.GeoM.Scale(cellWidth/float64(set.tileWidth), cellHeight/float64(set.tileHeight))
+				op.GeoM.Translate(float64(x)*cellWidth, float64(y)*cellHeight)
+				m.image.DrawImage(set.tileImage(t), op)
+			}
+		}
+	}
+}
+
+// worldToMinimap returns the screen position on the minimap of the world
+// position wx,wy.
+func (m *minimap) worldToMinimap(wx, wy float64) (float32, float32) {
+	r := m.rect()
+	w, h := m.sc.tiles.tilePixelDimensions()
+	return float32(float64(r.Min.X) + wx*float64(r.Dx())/float64(w)),
+		float32(float64(r.Min.Y) + wy*float64(r.Dy())/float64(h))
+}
+
+// minimapToWorld returns the world position shown on the minimap at the
+// screen position sx,sy.
+func (m *minimap) minimapToWorld(sx, sy int) (float64, float64) {
+	r := m.rect()
+	w, h := m.sc.tiles.tilePixelDimensions()
+	return float64(sx-r.Min.X) * float64(w) / float64(r.Dx()),
+		float64(sy-r.Min.Y) * float64(h) / float64(r.Dy())
+}
+
+// contains reports whether the screen position sx,sy is on the minimap.
+func (m *minimap) contains(sx, sy int) bool {
+	return image.Pt(sx, sy).In(m.rect())
+}
+
+// click moves the main camera to the world shown at the screen position
+// sx,sy. It returns false if the position is not on the minimap.
+func (m *minimap) click(sx, sy int) bool {
+	if !m.contains(sx, sy) {
+		return false
+	}
+	cam := m.sc.cam
+	cam.setTarget(nil)
+	cam.centerOn(m.minimapToWorld(sx, sy))
+	return true
+}
+
+// draw draws the minimap, rendering it again if tiles changed.
+func (m *minimap) draw(screen *ebiten.Image) {
+	if m.image == nil || m.version != m.sc.tiles.version ||
+		m.image.Bounds().Size() != m.rect().Size() {
+		m.render()
+	}
+
+	r := m.rect()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(r.Min.X), float64(r.Min.Y))
+	op.ColorScale.ScaleAlpha(0.85)
+	screen.DrawImage(m.image, op)
+
+	gray := color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+	drawDebugRect(screen, float32(r.Min.X), float32(r.Min.Y),
+		float32(r.Max.X), float32(r.Max.Y), gray)
+
+	// camera rectangles, the main one on top. A cyclic camera view that
+	// wraps around the tilemap edges is shown split at the edges.
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	for i := len(m.sc.cams) - 1; i >= 0; i-- {
+		cam := m.sc.cams[i]
+		rectColor := gray
+		if cam == m.sc.cam {
+			rectColor = white
+		}
+		for _, wr := range m.viewRects(cam) {
+			x1, y1 := m.worldToMinimap(float64(wr.Min.X), float64(wr.Min.Y))
+			x2, y2 := m.worldToMinimap(float64(wr.Max.X), float64(wr.Max.Y))
+			drawDebugRect(screen, x1, y1, x2, y2, rectColor)
+		}
+	}
+
+	// sprite blips
+	red := color.RGBA{0xff, 0x40, 0x40, 0xff}
+	yellow := color.RGBA{0xff, 0xff, 0x00, 0xff}
+	for _, spr := range m.sc.sprites {
+		blipColor := red
+		if spr == m.sc.player {
+			blipColor = yellow
+		}
+		x, y := m.sc.cam.world().Normalize(
+			spr.x+float64(spr.width)/2, spr.y+float64(spr.height)/2)
+		bx, by := m.worldToMinimap(x, y)
+		vector.FillRect(screen, bx-1, by-1, 3, 3, blipColor, false)
+	}
+}
+
+// viewRects returns the world rectangles seen by the camera, clipped to
+// the tilemap: the quadrants of a cyclic camera, or a single one.
+func (m *minimap) viewRects(cam *camera) []image.Rectangle {
+	viewWidth, viewHeight := cam.viewSize()
+	world := image.Rect(0, 0, m.sc.tiles.tilePixelWidth(), m.sc.tiles.tilePixelHeight())
+	if !cam.cyclic {
+		x, y, _, _ := cam.origin()
+		return []image.Rectangle{image.Rect(x, y, x+viewWidth, y+viewHeight).Intersect(world)}
+	}
+	var rects []image.Rectangle
+	for _, q := range m.sc.tiles.getQuadrants(cam, viewWidth, viewHeight) {
+		if q.draw {
+			r := image.Rect(q.worldX, q.worldY, q.worldX+q.width, q.worldY+q.height)
+			rects = append(rects, r.Intersect(world))
+		}
+	}
+	return rects
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+// go test -count 1 -run '^TestPeekTiles$' ./...
+func TestPeekTiles(t *testing.T) {
+	ts := newTestTiles(40, singleTileSource(tileSpace), singleTileSource(tileEmpty))
+
+	if gids := ts.peekTiles(20, 30); gids[0] != tileSpace || gids[1] != tileEmpty {
+		t.Errorf("wrong tiles from source: %v", gids)
+	}
+	if len(ts.chunks) != 0 {
+		t.Errorf("peek loaded %d chunks", len(ts.chunks))
+	}
+
+	version := ts.version
+	ts.setTile(1, 20, 30, tileAsteroid)
+	if ts.version == version {
+		t.Errorf("tile change did not change the version")
+	}
+	if gids := ts.peekTiles(20, 30); gids[0] != tileSpace || gids[1] != tileAsteroid {
+		t.Errorf("wrong tiles from chunk: %v", gids)
+	}
+}
+
+// go test -count 1 -run '^TestMinimap$' ./...
+func TestMinimap(t *testing.T) {
+	sc := newTestScene(80, true, sceneOptions{}) // 1280x1280 world
+	m := newMinimap(sc)
+
+	r := m.rect()
+	if r != image.Rect(minimapMargin, 600-minimapMargin-minimapMaxSize,
+		minimapMargin+minimapMaxSize, 600-minimapMargin) {
+		t.Fatalf("wrong rectangle: %v", r)
+	}
+
+	// 8 world pixels per minimap pixel
+	if x, y := m.worldToMinimap(640, 1280); x != float32(r.Min.X+80) || y != float32(r.Max.Y) {
+		t.Errorf("wrong minimap position: %v,%v", x, y)
+	}
+	if x, y := m.minimapToWorld(r.Min.X+80, r.Min.Y+40); x != 640 || y != 320 {
+		t.Errorf("wrong world position: %v,%v", x, y)
+	}
+
+	// the cyclic view across the corner is split in four
+	sc.cam.x, sc.cam.y = 1000, 1000
+	rects := m.viewRects(sc.cam)
+	if len(rects) != 4 {
+		t.Fatalf("wrong view rectangles: %v", rects)
+	}
+	var area int
+	for _, wr := range rects {
+		area += wr.Dx() * wr.Dy()
+	}
+	if area != 800*600 {
+		t.Errorf("wrong view area: %d", area)
+	}
+
+	// clicking centers the camera, releasing its target
+	sc.cam.setTarget(&sprite{})
+	if m.click(0, 0) {
+		t.Errorf("click outside the minimap")
+	}
+	if !m.click(r.Min.X+80, r.Min.Y+40) {
+		t.Fatalf("click missed the minimap")
+	}
+	if sc.cam.target != nil {
+		t.Errorf("camera still follows its target")
+	}
+	if sc.cam.x != 640-400 || sc.cam.y != 320-300 {
+		t.Errorf("wrong camera position: %v,%v", sc.cam.x, sc.cam.y)
+	}
+}
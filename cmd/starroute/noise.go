@@ -0,0 +1,90 @@
+package main
+
+import "github.com/udhos/starroute/torus"
+
+// splitmix64 scrambles z into a well distributed pseudo-random value.
+// See https://prng.di.unimi.it/splitmix64.c
+func splitmix64(z uint64) uint64 {
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// hash2 returns a pseudo-random value for the integer point x,y.
+// The same seed and point always give the same value.
+func hash2(seed uint64, x, y int) uint64 {
+	return splitmix64(splitmix64(splitmix64(seed)^uint64(x)) ^ uint64(y))
+}
+
+// hash2Float returns a pseudo-random value in [0,1) for the integer point x,y.
+func hash2Float(seed uint64, x, y int) float64 {
+	return float64(hash2(seed, x, y)>>11) / (1 << 53)
+}
+
+// valueNoise returns smooth noise in [0,1) for the point x,y.
+// Random values at integer lattice points are interpolated in between.
+// If periodX or periodY are positive, the noise repeats every period
+// lattice cells along that axis, so it tiles seamlessly.
+func valueNoise(seed uint64, x, y float64, periodX, periodY int) float64 {
+	x0 := floorInt(x)
+	y0 := floorInt(y)
+
+	// smoothstep the fractional part to hide the lattice
+	fx := smoothstep(x - float64(x0))
+	fy := smoothstep(y - float64(y0))
+
+	x1 := x0 + 1
+	y1 := y0 + 1
+	if periodX > 0 {
+		x0 = torus.WrapInt(x0, periodX)
+		x1 = torus.WrapInt(x1, periodX)
+	}
+	if periodY > 0 {
+		y0 = torus.WrapInt(y0, periodY)
+		y1 = torus.WrapInt(y1, periodY)
+	}
+
+	v00 := hash2Float(seed, x0, y0)
+	v10 := hash2Float(seed, x1, y0)
+	v01 := hash2Float(seed, x0, y1)
+	v11 := hash2Float(seed, x1, y1)
+
+	top := lerp(v00, v10, fx)
+	bottom := lerp(v01, v11, fx)
+	return lerp(top, bottom, fy)
+}
+
+// fractalNoise sums octaves of value noise, each one with double the
+// frequency and half the amplitude of the previous one. The result is
+// normalized to [0,1). The periods apply to the first octave.
+func fractalNoise(seed uint64, x, y float64, octaves, periodX, periodY int) float64 {
+	var sum, total float64
+	amplitude := 1.0
+	for o := range octaves {
+		sum += amplitude * valueNoise(seed+uint64(o), x, y, periodX, periodY)
+		total += amplitude
+		amplitude /= 2
+		x *= 2
+		y *= 2
+		periodX *= 2
+		periodY *= 2
+	}
+	return sum / total
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+func floorInt(x float64) int {
+	i := int(x)
+	if x < float64(i) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/udhos/starroute/torus"
+)
+
+// parallaxLayer is a background image that scrolls slower than the
+// tilemap, drawn behind it to give the scene depth. It shows through the
+// empty and transparent tiles.
+type parallaxLayer struct {
+	image         *ebiten.Image
+	width, height int
+
+	// factor is the scroll speed relative to the tilemap: 0 stays fixed
+	// on the screen, 1 moves along with the tilemap.
+	factor float64
+
+	// repeat tiles the image over the whole view.
+	repeat bool
+
+	// x,y is the position of the image in the view when the camera is at
+	// the world origin.
+	x, y float64
+}
+
+// addParallax adds a parallax layer in front of the ones already added.
+func (sc *scene) addParallax(img *ebiten.Image, factor float64, repeat bool) *parallaxLayer {
+	b := img.Bounds()
+	sc.parallax = append(sc.parallax, &parallaxLayer{
+		image:  img,
+		width:  b.Dx(),
+		height: b.Dy(),
+		factor: factor,
+		repeat: repeat,
+	})
+	return sc.parallax[len(sc.parallax)-1]
+}
+
+// origin returns the position of the image in the view for the camera at
+// camX,camY. Over a cyclic world of worldWidth x worldHeight pixels, the
+// layer must line up again when the camera wraps around the seam.
+// A repeating layer scrolls at the closest factor that scrolls a whole
+// number of images per world, so it has no seam. A single image is
+// shown at its copy closest to the view center, viewWidth x viewHeight.
+func (l *parallaxLayer) origin(camX, camY int, cyclic bool,
+	worldWidth, worldHeight, viewWidth, viewHeight int) (float64, float64) {
+
+	if !cyclic {
+		return l.x - float64(camX)*l.factor, l.y - float64(camY)*l.factor
+	}
+
+	if l.repeat {
+		fx := cyclicParallaxFactor(l.factor, worldWidth, l.width)
+		fy := cyclicParallaxFactor(l.factor, worldHeight, l.height)
+		return l.x - float64(camX)*fx, l.y - float64(camY)*fy
+	}
+
+	x := l.x - float64(camX)*l.factor
+	y := l.y - float64(camY)*l.factor
+	if l.factor > 0 {
+		centerX := float64(viewWidth-l.width) / 2
+		centerY := float64(viewHeight-l.height) / 2
+		period := torus.New(float64(worldWidth)*l.factor, float64(worldHeight)*l.factor)
+		x, y = period.Nearest(x, y, centerX, centerY)
+	}
+	return x, y
+}
+
+// cyclicParallaxFactor returns the scroll factor closest to factor that
+// scrolls a whole number of images of imageSize pixels along a world of
+// worldSize pixels.
+func cyclicParallaxFactor(factor float64, worldSize, imageSize int) float64 {
+	if factor == 0 {
+		return 0
+	}
+	n := max(1, math.Round(factor*float64(worldSize)/float64(imageSize)))
+	return n * float64(imageSize) / float64(worldSize)
+}
+
+// drawParallax draws the parallax layers seen by the camera, farthest
+// first, into screen at 1:1 scale.
+func (sc *scene) drawParallax(screen *ebiten.Image, cam *camera) {
+	if len(sc.parallax) == 0 {
+		return
+	}
+	camX, camY, _, _ := cam.origin()
+	worldWidth, worldHeight := sc.tiles.tilePixelDimensions()
+	viewWidth, viewHeight := screen.Bounds().Dx(), screen.Bounds().Dy()
+
+	op := &ebiten.DrawImageOptions{}
+	for _, l := range sc.parallax {
+		x, y := l.origin(camX, camY, cam.cyclic, worldWidth, worldHeight, viewWidth, viewHeight)
+		if !l.repeat {
+			op.GeoM.Reset()
+			op.GeoM.Translate(math.Floor(x), math.Floor(y))
+			screen.DrawImage(l.image, op)
+			continue
+		}
+		w, h := float64(l.width), float64(l.height)
+		startX := math.Floor(torus.Wrap(x, w)) - w
+		startY := math.Floor(torus.Wrap(y, h)) - h
+		for ty := startY; ty < float64(viewHeight); ty += h {
+			for tx := startX; tx < float64(viewWidth); tx += w {
+				op.GeoM.Reset()
+				op.GeoM.Translate(tx, ty)
+				screen.DrawImage(l.image, op)
+			}
+		}
+	}
+}
+
+// newSpaceBackdrop returns a function adding the deep space backdrop to a
+// scene, shown through the void tiles, which are no longer drawn: a nebula
+// and two starfields, farthest first. The images are shared by the scenes.
+func newSpaceBackdrop(seed uint64) func(sc *scene) {
+	nebula := newNebula(seed, 256, color.RGBA{0x60, 0x30, 0x90, 0xa0})
+	farStars := newStarfield(seed+1, 256, 90, 0.5)
+	nearStars := newStarfield(seed+2, 512, 50, 1)
+	return func(sc *scene) {
+		sc.tiles.seeThrough = tileVoid
+		sc.addParallax(nebula, 0.05, true)
+		sc.addParallax(farStars, 0.2, true)
+		sc.addParallax(nearStars, 0.5, true)
+	}
+}
+
+// newStarfield returns a square image of size pixels with count stars
+// scattered by seed. It tiles seamlessly.
+func newStarfield(seed uint64, size, count int, brightness float64) *ebiten.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := range count {
+		x := int(hash2Float(seed, i, 0) * float64(size))
+		y := int(hash2Float(seed, i, 1) * float64(size))
+		b := uint8(255 * brightness * (0.4 + 0.6*hash2Float(seed, i, 2)))
+		img.SetRGBA(x, y, color.RGBA{b, b, b, 0xff})
+	}
+	return ebiten.NewImageFromImage(img)
+}
+
+// newNebula returns a square image of size pixels of clouds tinted by c,
+// from periodic noise, so it tiles seamlessly.
+func newNebula(seed uint64, size int, c color.RGBA) *ebiten.Image {
+	const (
+		cells   = 4 // noise lattice cells along the image edge
+		octaves = 4
+	)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			v := fractalNoise(seed, float64(x*cells)/float64(size), float64(y*cells)/float64(size),
+				octaves, cells, cells)
+			a := max(0, min(1, (v-0.45)*2)) * float64(c.A) / 255
+			// premultiplied alpha
+			img.SetRGBA(x, y, color.RGBA{
+				uint8(float64(c.R) * a), uint8(float64(c.G) * a), uint8(float64(c.B) * a),
+				uint8(255 * a)})
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
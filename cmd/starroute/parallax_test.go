@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type parallaxTest struct {
+	name       string
+	layer      parallaxLayer
+	camX, camY int
+	cyclic     bool
+	expectedX  float64
+	expectedY  float64
+}
+
+// world 1000x800 pixels, view 200x100 pixels
+var parallaxTestTable = []parallaxTest{
+	{"clamped far", parallaxLayer{width: 100, height: 100, factor: 0.5}, 100, 40, false, -50, -20},
+	{"clamped fixed", parallaxLayer{width: 100, height: 100, factor: 0, x: 10, y: 20}, 100, 40, false, 10, 20},
+	{"clamped tilemap", parallaxLayer{width: 100, height: 100, factor: 1}, 100, 40, false, -100, -40},
+	// 0.42 scrolls 4.2 images along the width, rounded to 4, and 3.36 along
+	// the height, rounded to 3
+	{"cyclic repeat", parallaxLayer{width: 100, height: 100, factor: 0.42, repeat: true}, 500, 400, true, -200, -150},
+	{"cyclic repeat wrapped", parallaxLayer{width: 100, height: 100, factor: 0.42, repeat: true}, 1000, 800, true, -400, -300},
+	{"cyclic repeat at least once", parallaxLayer{width: 100, height: 100, factor: 0.01, repeat: true}, 1000, 800, true, -100, -100},
+	{"cyclic repeat fixed", parallaxLayer{width: 100, height: 100, factor: 0, repeat: true}, 1000, 800, true, 0, 0},
+	// single image wraps every 500x400 pixels, nearest to the view center
+	{"cyclic single", parallaxLayer{width: 100, height: 100, factor: 0.5, x: 50}, 100, 40, true, 0, -20},
+	{"cyclic single wrapped", parallaxLayer{width: 100, height: 100, factor: 0.5, x: 50}, 900, 780, true, 100, 10},
+	{"cyclic single fixed", parallaxLayer{width: 100, height: 100, factor: 0, x: 50}, 900, 780, true, 50, 0},
+}
+
+// go test -count 1 -run '^TestParallaxOrigin$' ./...
+func TestParallaxOrigin(t *testing.T) {
+	for i, data := range parallaxTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(parallaxTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			x, y := data.layer.origin(data.camX, data.camY, data.cyclic, 1000, 800, 200, 100)
+			if x != data.expectedX || y != data.expectedY {
+				t.Errorf("expected %vx%v, got %vx%v", data.expectedX, data.expectedY, x, y)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestSpaceBackdrop$' ./...
+func TestSpaceBackdrop(t *testing.T) {
+	screen := ebiten.NewImage(640, 640)
+	defer screen.Deallocate()
+
+	// 40x40 tiles, or 3x3 chunks, of void with space in the first chunk
+	newVoidTiles := func() *tiles {
+		ts := newTestTiles(40, singleTileSource(tileVoid))
+		for row := range 16 {
+			for col := range 16 {
+				ts.setTile(0, col, row, tileSpace)
+			}
+		}
+		return ts
+	}
+
+	sc := newTestScene(40, false, sceneOptions{})
+	sc.tiles = newVoidTiles()
+	if blits := sc.tiles.drawQuadrant(screen, 0, 0, 640, 640, 0, 0); blits != 9 {
+		t.Fatalf("expected opaque void without a backdrop: got %d blits", blits)
+	}
+
+	sc.tiles = newVoidTiles()
+	newSpaceBackdrop(1)(sc)
+	if len(sc.parallax) == 0 {
+		t.Fatalf("no parallax layers")
+	}
+	// only the space chunk is drawn, the backdrop shows through the void
+	if blits := sc.tiles.drawQuadrant(screen, 0, 0, 640, 640, 0, 0); blits != 1 {
+		t.Errorf("expected void not drawn: got %d blits", blits)
+	}
+	if sc.tiles.drawTile(screen, tileVoid, 0, 0) {
+		t.Errorf("void tile drawn over the backdrop")
+	}
+}
@@ -3,7 +3,11 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"image"
+	"image/color"
 	"log"
+	"path"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
@@ -11,70 +15,99 @@ import (
 	"github.com/udhos/starroute/music"
 )
 
-const (
-	sceneTrack1 = iota
-	sceneTrack2
-	sceneTrack3
-)
-
 type scene struct {
+	name         string
 	sprites      []*sprite
 	tiles        *tiles
 	musicPlayer  *music.Player
-	musicTrack   int
+	music        string // asset file or builtin track, empty for none
 	audioContext *audio.Context
 	cam          *camera
 	g            *game
 	uiCoord      string
 	showCoord    bool
 	opt          sceneOptions
+	player       *sprite         // steered by the player, nil if none
+	terrain      tileProps       // properties of the tiles under the player
+	input        string          // input profile, a key of sceneInputs
+	overlay      sceneOverlay    // how the scene shows over the scenes below
+	behavior     sceneBehavior   // the logic of the scene
+	transitionIn transitionStyle // how the game switches into the scene
+
+	// cams are the cameras drawn in order, each into its viewport.
+	// cams[0] is the main camera cam, driven by the input.
+	cams     []*camera
+	viewMode int
+
+	minimap *minimap
+
+	// parallax are the background layers behind the tilemap, farthest
+	// first.
+	parallax []*parallaxLayer
 }
 
 type sceneOptions struct {
 	banner string
+
+	// camera zoom limits, zero for the defaults camZoomMin and camZoomMax
+	minZoom, maxZoom float64
 }
 
-func newScene(g *game, ts *tiles, musicTrack int,
+func newScene(g *game, ts *tiles, music string,
 	audioContext *audio.Context, cyclicCamera,
 	centralizeCamera, showCoord bool, opt sceneOptions) *scene {
 	sc := &scene{
 		g:            g,
 		tiles:        ts,
-		musicTrack:   musicTrack,
+		music:        music,
 		audioContext: audioContext,
 		uiCoord:      "? ?",
 		showCoord:    showCoord,
 		opt:          opt,
+		input:        "play",
+		behavior:     &flightBehavior{},
+		transitionIn: sceneTransitionDefault,
 	}
 	sc.cam = newCamera(sc, cyclicCamera, centralizeCamera)
+	sc.cams = []*camera{sc.cam}
+	sc.minimap = newMinimap(sc)
 	return sc
 }
 
+// builtinMusic are the tracks built into the game, by name.
+var builtinMusic = map[string][]byte{
+	"ragtime": raudio.Ragtime_mp3,
+}
+
 func (sc *scene) musicStart() {
 	sc.musicStop()
-	//m, err := music.NewPlayer(audioContext, music.TypeOgg, bytes.NewReader(raudio.Ragtime_ogg))
-	//m, err := music.NewPlayer(audioContext, music.TypeMP3, bytes.NewReader(raudio.Ragtime_mp3))
-
-	var m *music.Player
-	var err error
+	if sc.music == "" {
+		return // no music
+	}
 
-	switch sc.musicTrack {
-	case sceneTrack1:
-		const input = "champions-victory-winner-background-music-388566.mp3"
-		data := mustLoadAsset("musicStart", input)
-		m, err = music.NewPlayer(sc.audioContext, music.TypeMP3, bytes.NewReader(data))
-	case sceneTrack2:
-		m, err = music.NewPlayer(sc.audioContext, music.TypeMP3, bytes.NewReader(raudio.Ragtime_mp3))
-	case sceneTrack3:
-		// no music
+	data, found := builtinMusic[sc.music]
+	if !found {
+		data = mustLoadAsset("musicStart", sc.music)
+	}
+	musicType := music.TypeMP3
+	if strings.EqualFold(path.Ext(sc.music), ".ogg") {
+		musicType = music.TypeOgg
 	}
 
+	m, err := music.NewPlayer(sc.audioContext, musicType, bytes.NewReader(data))
 	if err != nil {
 		log.Fatalf("scene.musicStart error: %v", err)
 	}
 	sc.musicPlayer = m
 }
 
+// musicVolume sets the volume of the scene music, from 0 to 1.
+func (sc *scene) musicVolume(volume float64) {
+	if sc.musicPlayer != nil {
+		sc.musicPlayer.SetVolume(volume)
+	}
+}
+
 func (sc *scene) musicStop() {
 	if sc.musicPlayer == nil {
 		return
@@ -83,7 +116,7 @@ func (sc *scene) musicStop() {
 	sc.musicPlayer = nil
 }
 
-func (sc *scene) addSprite(x, y, angleNative float64, spriteImage *ebiten.Image) {
+func (sc *scene) addSprite(x, y, angleNative float64, spriteImage *ebiten.Image) *sprite {
 	w, h := spriteImage.Bounds().Dx(), spriteImage.Bounds().Dy()
 	spr := sprite{
 		x:           x,
@@ -92,17 +125,72 @@ func (sc *scene) addSprite(x, y, angleNative float64, spriteImage *ebiten.Image)
 		height:      h,
 		angleNative: angleNative,
 		image:       spriteImage,
+		spin:        spriteSpin,
 	}
 	sc.sprites = append(sc.sprites, &spr)
+	return &spr
 }
 
-func (sc *scene) update() {
+// addAnimatedSprite adds a sprite playing anim, of the size of the sheet
+// frames.
+func (sc *scene) addAnimatedSprite(x, y, angleNative float64, anim *animation) *sprite {
+	spr := &sprite{
+		x:           x,
+		y:           y,
+		width:       anim.sheet.width,
+		height:      anim.sheet.height,
+		angleNative: angleNative,
+		anim:        anim,
+		spin:        spriteSpin,
+	}
+	sc.sprites = append(sc.sprites, spr)
+	return spr
+}
 
-	sc.uiCoord = sc.getWorldCoordinates()
+// setPlayer makes spr the player sprite, followed by the camera.
+func (sc *scene) setPlayer(spr *sprite) {
+	spr.spin = 0
+	sc.player = spr
+	sc.cam.setTarget(spr)
+	if spr.anim != nil {
+		spr.anim.onEvent = sc.playerAnimEvent
+	}
+}
+
+// playerAnimEvent reacts to the events of the player animation: the
+// blast of the explosion shakes the camera, and the ship is back after
+// it.
+func (sc *scene) playerAnimEvent(clip, event string) {
+	switch {
+	case event == "blast":
+		sc.cam.shake(0.8)
+	case clip == "explode" && event == "end":
+		sc.player.anim.play("idle")
+	}
+}
+
+// update advances the scene by one tick of dt seconds.
+func (sc *scene) update(dt float64) {
 
 	// Update all sprites.
 	for _, spr := range sc.sprites {
-		spr.update()
+		x, y := spr.x, spr.y
+		spr.update(dt)
+		if spr == sc.player {
+			sc.reactToTerrain(x, y)
+		}
+		spr.wrap(sc.cam.world())
+	}
+
+	for _, cam := range sc.cams {
+		cam.update(dt)
+	}
+
+	sc.uiCoord = sc.getWorldCoordinates()
+
+	sc.tiles.update()
+	for _, cam := range sc.cams {
+		sc.prefetchTiles(cam)
 	}
 
 	if sc.musicPlayer != nil {
@@ -112,15 +200,164 @@ func (sc *scene) update() {
 	}
 }
 
+// spriteProps returns the properties of the tiles under the sprite,
+// so gameplay can react to the terrain.
+func (sc *scene) spriteProps(spr *sprite) tileProps {
+	return sc.tiles.overlappingProps(spr.bounds(), sc.cam.cyclic)
+}
+
+// hazardFlash is the color of the flash when the player enters a hazard.
+var hazardFlash = color.RGBA{0xff, 0x20, 0x20, 0xff}
+
+// movedIntoSolid reports whether the sprite, moved from fromX,fromY, is
+// over solid tiles that were not under it before.
+func (sc *scene) movedIntoSolid(spr *sprite, fromX, fromY float64) bool {
+	type cell struct{ col, row int }
+	before := map[cell]bool{}
+	for _, hit := range sc.tiles.overlapping(spr.boundsAt(fromX, fromY), sc.cam.cyclic) {
+		if hit.props.solid {
+			before[cell{hit.col, hit.row}] = true
+		}
+	}
+	for _, hit := range sc.tiles.overlapping(spr.bounds(), sc.cam.cyclic) {
+		if hit.props.solid && !before[cell{hit.col, hit.row}] {
+			return true
+		}
+	}
+	return false
+}
+
+// reactToTerrain makes the player, moved from fromX,fromY, react to the
+// tiles under it: solid tiles block it, slow zones brake it, and entering
+// a hazard shakes the camera with a red flash. A player already on solid
+// tiles, like at the start, can move off them, but not onto other ones.
+func (sc *scene) reactToTerrain(fromX, fromY float64) {
+	spr := sc.player
+	props := sc.spriteProps(spr)
+	if props.solid && sc.movedIntoSolid(spr, fromX, fromY) {
+		spr.x, spr.y = fromX, fromY
+		spr.vx, spr.vy = 0, 0
+		props = sc.spriteProps(spr)
+	}
+	if props.slow > 0 {
+		spr.limitSpeed(spriteMaxSpeed * props.slow)
+	}
+	if props.hazard && !sc.terrain.hazard {
+		sc.cam.shake(0.5)
+		sc.cam.flash(hazardFlash, 0.4, 0.5)
+	}
+	sc.terrain = props
+}
+
+// prefetchTiles loads the tile chunks around the camera view,
+// so they are ready when the camera moves.
+func (sc *scene) prefetchTiles(cam *camera) {
+	margin := tileChunkSize * sc.tiles.tileSize
+	viewWidth, viewHeight := cam.viewSize()
+
+	if cam.cyclic {
+		quads := sc.tiles.getQuadrants(cam, viewWidth, viewHeight)
+		for _, q := range quads {
+			if !q.draw {
+				continue
+			}
+			r := image.Rect(q.worldX, q.worldY, q.worldX+q.width, q.worldY+q.height)
+			sc.tiles.prefetch(r.Inset(-margin))
+		}
+		return
+	}
+
+	camX, camY, _, _ := cam.origin()
+	r := image.Rect(camX, camY, camX+viewWidth, camY+viewHeight)
+	sc.tiles.prefetch(r.Inset(-margin))
+}
+
+// draw draws the scene on the screen, through each camera into its
+// viewport. The UI is drawn at the native screen resolution.
 func (sc *scene) draw(screen *ebiten.Image, debug bool) int {
+	var countTiles int
+	for _, cam := range sc.cams {
+		countTiles += sc.drawCamera(screen, cam, debug)
+	}
+	for _, cam := range sc.cams {
+		cam.drawFrame(screen)
+	}
+	if sc.minimapShown() {
+		sc.minimap.draw(screen)
+	}
+
+	sc.drawSimpleUI(screen)
+
+	return countTiles
+}
+
+// minimapShown reports whether the minimap is on the screen. It is shown
+// along with the coordinates, in game scenes.
+func (sc *scene) minimapShown() bool {
+	return sc.showCoord && sc.g.showMinimap
+}
+
+// drawCamera draws the world seen by the camera into its viewport.
+// The world is drawn at 1:1 scale into the camera view image, which is
+// then scaled by the camera zoom onto the screen.
+func (sc *scene) drawCamera(screen *ebiten.Image, cam *camera, debug bool) int {
+	r := cam.screenRect()
+	_, _, shiftX, shiftY := cam.origin()
+
+	if cam.zoom == 1 && r == screen.Bounds() {
+		// no shift, the camera origin is snapped to screen pixels
+		countTiles := sc.drawWorld(screen, cam, debug)
+		cam.drawFX(screen)
+		return countTiles
+	}
+
+	// one more world pixel to cover the shift
+	viewWidth, viewHeight := cam.viewSize()
+	viewWidth++
+	viewHeight++
+
+	// the view image only grows, so zooming does not allocate every
+	// frame, and the world is drawn into the part of it in use
+	if b := cam.view; b == nil || b.Bounds().Dx() < viewWidth || b.Bounds().Dy() < viewHeight {
+		w, h := viewWidth, viewHeight
+		if b != nil {
+			w, h = max(w, b.Bounds().Dx()), max(h, b.Bounds().Dy())
+			b.Deallocate()
+		}
+		cam.view = ebiten.NewImage(w, h)
+	}
+	view := cam.view.SubImage(image.Rect(0, 0, viewWidth, viewHeight)).(*ebiten.Image)
+	view.Clear()
+
+	countTiles := sc.drawWorld(view, cam, debug)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(cam.zoom, cam.zoom)
+	op.GeoM.Translate(float64(r.Min.X)-shiftX, float64(r.Min.Y)-shiftY)
+	if cam.zoom < 1 {
+		// smooth shrinking, nearest filter keeps pixel art sharp when enlarging
+		op.Filter = ebiten.FilterLinear
+	}
+	// the sub-image clips the view to the viewport
+	screen.SubImage(r).(*ebiten.Image).DrawImage(view, op)
+
+	cam.drawFX(screen)
+
+	return countTiles
+}
+
+// drawWorld draws the tiles and sprites seen by the camera at 1:1 scale.
+func (sc *scene) drawWorld(screen *ebiten.Image, cam *camera, debug bool) int {
 
 	var quads [4]quad
 
-	if sc.cam.cyclic {
-		quads = sc.tiles.getQuadrants(sc.cam, screen.Bounds().Dx(), screen.Bounds().Dy())
+	if cam.cyclic {
+		quads = sc.tiles.getQuadrants(cam, screen.Bounds().Dx(), screen.Bounds().Dy())
 	}
 
-	countTiles := sc.tiles.draw(screen, sc.cam, debug, &quads)
+	sc.drawParallax(screen, cam)
+
+	countTiles := sc.tiles.draw(screen, cam, debug, &quads)
 
 	// Draw each sprite.
 	// DrawImage can be called many many times, but in the implementation,
@@ -132,17 +369,13 @@ func (sc *scene) draw(screen *ebiten.Image, debug bool) int {
 	// For cyclic camera, sprites must be drawn once per
 	// visible quadrant using the quadrant's world origin and cam offset so
 	// they appear in wrapped positions.
-	if sc.cam.cyclic {
+	if cam.cyclic {
 		for _, q := range quads {
 			if !q.draw {
 				continue
 			}
-			// camX/camY expected by sprite.draw are subtracted from sprite
-			// world coordinates. To achieve the same placement used for
-			// tiles (screen = world - worldX + camOffset), pass
-			// cam = worldX - camOffset.
-			camX := float64(q.worldX - q.camOffsetX)
-			camY := float64(q.worldY - q.camOffsetY)
+			// same placement used for tiles
+			camX, camY := q.origin()
 
 			var op ebiten.DrawImageOptions
 			for i := 0; i < len(sc.sprites); i++ {
@@ -153,8 +386,9 @@ func (sc *scene) draw(screen *ebiten.Image, debug bool) int {
 	} else {
 		// For non-cyclic camera draw once.
 
-		camX := float64(sc.cam.x)
-		camY := float64(sc.cam.y)
+		x, y, _, _ := cam.origin()
+		camX := float64(x)
+		camY := float64(y)
 
 		var op ebiten.DrawImageOptions
 
@@ -164,8 +398,6 @@ func (sc *scene) draw(screen *ebiten.Image, debug bool) int {
 		}
 	}
 
-	sc.drawSimpleUI(screen)
-
 	return countTiles
 }
 
@@ -173,8 +405,7 @@ func (sc *scene) draw(screen *ebiten.Image, debug bool) int {
 // Example: "12N 34E"
 // It is used in the game UI to give feedback to the player about their current location.
 func (sc *scene) getWorldCoordinates() string {
-	camX := sc.cam.x
-	camY := sc.cam.y
+	camX, camY, _, _ := sc.cam.origin()
 	camXmax := sc.cam.maxX()
 	camYmax := sc.cam.maxY()
 	camXmid := camXmax / 2
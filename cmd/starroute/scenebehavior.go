@@ -0,0 +1,114 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// sceneBehavior is the logic of a kind of scene, like the title screen or
+// space flight, on top of the camera, tile and sprite machinery shared by
+// all scenes. Behaviors embed sceneBehaviorBase for the shared defaults
+// and override the hooks they need.
+type sceneBehavior interface {
+	enter(sc *scene) // the scene joins the stack
+	exit(sc *scene)  // the scene leaves the stack
+	update(sc *scene, dt float64)
+	draw(sc *scene, screen *ebiten.Image, debug bool) int // returns the tiles drawn
+
+	// layout reports the size of the window, when it changes while the
+	// scene is on the stack.
+	layout(sc *scene, outsideWidth, outsideHeight int)
+}
+
+// scenePauser is implemented by behaviors that care about being covered
+// by an overlay, which takes the input from them.
+type scenePauser interface {
+	pause(sc *scene)  // an overlay is pushed over the scene
+	resume(sc *scene) // the scene is back on top of the stack
+}
+
+// sceneBehaviors are the behaviors of scenes, by name.
+var sceneBehaviors = map[string]func() sceneBehavior{
+	"flight": func() sceneBehavior { return &flightBehavior{} },
+	"title":  func() sceneBehavior { return &titleBehavior{} },
+}
+
+// scenePause calls the pause hook of the scene behavior, if any.
+func scenePause(sc *scene) {
+	if p, ok := sc.behavior.(scenePauser); ok {
+		p.pause(sc)
+	}
+}
+
+// sceneResume calls the resume hook of the scene behavior, if any.
+func sceneResume(sc *scene) {
+	if p, ok := sc.behavior.(scenePauser); ok {
+		p.resume(sc)
+	}
+}
+
+// sceneBehaviorBase runs the shared scene machinery, with no hooks.
+type sceneBehaviorBase struct{}
+
+func (sceneBehaviorBase) enter(_ *scene) {}
+
+func (sceneBehaviorBase) exit(_ *scene) {}
+
+func (sceneBehaviorBase) update(sc *scene, dt float64) {
+	sc.update(dt)
+}
+
+func (sceneBehaviorBase) draw(sc *scene, screen *ebiten.Image, debug bool) int {
+	return sc.draw(screen, debug)
+}
+
+func (sceneBehaviorBase) layout(_ *scene, _, _ int) {}
+
+// flightPausedVolume is the music volume of a flight scene covered by an
+// overlay without music of its own.
+const flightPausedVolume = 0.3
+
+// flightBehavior is space flight, the default behavior. Its music goes
+// down while an overlay covers it.
+type flightBehavior struct {
+	sceneBehaviorBase
+}
+
+func (*flightBehavior) pause(sc *scene) {
+	sc.musicVolume(flightPausedVolume)
+}
+
+func (*flightBehavior) resume(sc *scene) {
+	sc.musicVolume(1)
+}
+
+// titleDriftSpeed is how fast the title screen camera drifts, in world
+// pixels per second.
+const titleDriftSpeed = 12
+
+// titleBehavior is the title screen: the camera drifts across the map,
+// turning back at its edges, so the backdrop slowly scrolls behind the
+// banner.
+type titleBehavior struct {
+	sceneBehaviorBase
+	dirX, dirY float64
+}
+
+// enter starts the drift from the center of the map.
+func (t *titleBehavior) enter(sc *scene) {
+	t.dirX, t.dirY = 1, 0.5
+	sc.cam.centerOn(float64(sc.tiles.tilePixelWidth())/2, float64(sc.tiles.tilePixelHeight())/2)
+}
+
+func (t *titleBehavior) update(sc *scene, dt float64) {
+	cam := sc.cam
+	x, y := cam.x+t.dirX*titleDriftSpeed*dt, cam.y+t.dirY*titleDriftSpeed*dt
+	cam.x, cam.y = x, y
+	cam.clamp()
+	if cam.x != x {
+		t.dirX = -t.dirX
+	}
+	if cam.y != y {
+		t.dirY = -t.dirY
+	}
+	sc.update(dt)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordBehavior records the hooks called, as "hook:scene".
+type recordBehavior struct {
+	sceneBehaviorBase
+	log *[]string
+}
+
+func (b *recordBehavior) record(hook string, sc *scene) {
+	*b.log = append(*b.log, hook+":"+sc.name)
+}
+
+func (b *recordBehavior) enter(sc *scene)  { b.record("enter", sc) }
+func (b *recordBehavior) exit(sc *scene)   { b.record("exit", sc) }
+func (b *recordBehavior) pause(sc *scene)  { b.record("pause", sc) }
+func (b *recordBehavior) resume(sc *scene) { b.record("resume", sc) }
+
+func (b *recordBehavior) layout(sc *scene, _, _ int) { b.record("layout", sc) }
+
+type sceneBehaviorTest struct {
+	name      string
+	stack     []int // scenes on the stack, bottom to top
+	op        func(g *game)
+	expectLog string
+}
+
+var sceneBehaviorTestTable = []sceneBehaviorTest{
+	{"push", []int{0}, func(g *game) { g.pushScene(g.scenes[2]) }, "pause:s0 enter:s2"},
+	{"pop", []int{0, 2}, func(g *game) { g.popScene() }, "exit:s2 resume:s0"},
+	{"pop last", []int{0}, func(g *game) { g.popScene() }, ""},
+	{"switch", []int{0}, func(g *game) { g.switchScene(1) }, "exit:s0 enter:s1"},
+	{"switch from overlay", []int{0, 2}, func(g *game) { g.switchScene(1) }, "exit:s0 exit:s2 enter:s1"},
+	{"switch to scene below", []int{0, 2}, func(g *game) { g.switchScene(0) }, "exit:s2 resume:s0"},
+	{"switch to itself", []int{1}, func(g *game) { g.switchScene(1) }, ""},
+	{"layout", []int{0, 2}, func(g *game) { g.Layout(640, 480) }, "layout:s0 layout:s2"},
+	{"same layout", []int{0, 2}, func(g *game) { g.Layout(800, 600) }, ""},
+}
+
+// go test -count 1 -run '^TestSceneBehavior$' ./...
+func TestSceneBehavior(t *testing.T) {
+	for i, data := range sceneBehaviorTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(sceneBehaviorTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			var log []string
+			g := &game{screenWidth: 800, screenHeight: 600, windowWidth: 800, windowHeight: 600}
+			for j := range 3 {
+				sc := newTestScene(10, false, sceneOptions{})
+				sc.g = g
+				sc.name = fmt.Sprintf("s%d", j)
+				sc.behavior = &recordBehavior{log: &log}
+				sc.transitionIn = transitionStyle{kind: transitionCut}
+				g.scenes = append(g.scenes, sc)
+			}
+			for _, j := range data.stack {
+				g.stack = append(g.stack, g.scenes[j])
+			}
+			g.sceneCurrent = data.stack[0]
+
+			data.op(g)
+
+			if got := strings.Join(log, " "); got != data.expectLog {
+				t.Errorf("expected hooks %q, got %q", data.expectLog, got)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestTitleDrift$' ./...
+func TestTitleDrift(t *testing.T) {
+	sc := newTestScene(100, false, sceneOptions{}) // 1600x1600 world pixels
+	b := &titleBehavior{}
+	b.enter(sc)
+	if sc.cam.x != 400 || sc.cam.y != 500 {
+		t.Fatalf("expected camera centered at 400,500, got %v,%v", sc.cam.x, sc.cam.y)
+	}
+
+	// drift to the right edge, then back
+	for range 100 {
+		b.update(sc, 1)
+	}
+	if b.dirX != -1 || sc.cam.x >= 800 {
+		t.Errorf("expected camera turned back from the right edge: x=%v dirX=%v", sc.cam.x, b.dirX)
+	}
+	if sc.cam.y < 0 || sc.cam.y > 1000 {
+		t.Errorf("camera y out of the map: %v", sc.cam.y)
+	}
+}
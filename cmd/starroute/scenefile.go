@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+// Scene files describe the scenes of the game, so new levels need no
+// recompiling. They are JSON files in the assets/scenes directory, and
+// the game shows them in file name order.
+
+// sceneDir is the asset directory of the scene files.
+const sceneDir = "scenes"
+
+// generatedTileSize is the tile size of images.Tiles_png used by
+// generated tilemaps.
+const generatedTileSize = 16
+
+// sceneDef describes a scene.
+type sceneDef struct {
+	Name string `json:"name"` // defaults to the file name
+
+	Map sceneMapDef `json:"map"`
+
+	// Music is an asset file, MP3 or Ogg, or a builtin track. Empty
+	// plays no music.
+	Music string `json:"music"`
+
+	Camera    sceneCameraDef `json:"camera"`
+	ShowCoord bool           `json:"showCoord"`
+	Banner    string         `json:"banner"`
+
+	// Input is the input profile: "play", the default, for all controls,
+	// "menu" for the start screen, to resume or quit, or "pause" for the
+	// pause screen, to resume. The first menu scene is the start screen,
+	// and the first pause scene is the pause screen. Menu and pause scenes
+	// are overlays, pushed on top of the scene being played.
+	Input string `json:"input"`
+
+	// Behavior is the logic of the scene: "flight", the default, or
+	// "title" for a title screen drifting over the map.
+	Behavior string `json:"behavior"`
+
+	// Overlay sets how the scene shows over the scenes below it, when
+	// pushed on top of them. Nil hides them.
+	Overlay *sceneOverlayDef `json:"overlay"`
+
+	// First is the scene to play first, defaults to the first one after
+	// the start screen.
+	First bool `json:"first"`
+
+	// Backdrop names the parallax layers behind the tilemap, "space" or
+	// empty for none.
+	Backdrop string `json:"backdrop"`
+
+	Sprites []spriteDef `json:"sprites"`
+
+	// Transition is how the game switches into the scene, defaults to
+	// sceneTransitionDefault.
+	Transition *sceneTransitionDef `json:"transition"`
+}
+
+// sceneTransitionDef describes the transition into a scene.
+type sceneTransitionDef struct {
+	Kind      string  `json:"kind"`      // cut, fade, crossfade or wipe
+	Seconds   float64 `json:"seconds"`   // duration, zero for the default
+	Direction string  `json:"direction"` // the wipe edge moves right (default), left, down or up
+}
+
+// style returns the transition style described.
+func (def *sceneTransitionDef) style() transitionStyle {
+	if def == nil {
+		return sceneTransitionDefault
+	}
+	dir := wipeDirections["right"]
+	if def.Direction != "" {
+		dir = wipeDirections[def.Direction]
+	}
+	seconds := def.Seconds
+	if seconds == 0 {
+		seconds = sceneTransitionDefault.seconds
+	}
+	return transitionStyle{
+		kind:    transitionKinds[def.Kind],
+		seconds: seconds,
+		dirX:    dir.X,
+		dirY:    dir.Y,
+	}
+}
+
+// sceneOverlayDef describes how an overlay scene treats the scenes below.
+type sceneOverlayDef struct {
+	DrawBelow   bool    `json:"drawBelow"`   // keep drawing the scene below
+	Dim         float64 `json:"dim"`         // dim the scene below, from 0 (not at all) to 1 (black)
+	UpdateBelow bool    `json:"updateBelow"` // keep updating the scene below
+}
+
+// overlay returns the overlay described.
+func (def *sceneOverlayDef) overlay() sceneOverlay {
+	if def == nil {
+		return sceneOverlay{}
+	}
+	return sceneOverlay{
+		drawBelow:   def.DrawBelow,
+		dim:         def.Dim,
+		updateBelow: def.UpdateBelow,
+	}
+}
+
+// sceneMapDef describes the tilemap of a scene, either loaded from a
+// Tiled map or generated.
+type sceneMapDef struct {
+	// File is the Tiled map, loaded unless the map is generated. The
+	// editor saves to it. Generated maps have no file, since they are
+	// generated again at every start.
+	File string `json:"file"`
+
+	// Generate is "galaxy" for a generated galaxy, "void" for empty
+	// space, or empty to load File.
+	Generate string `json:"generate"`
+
+	// Autotile picks the rules for the space borders of generated maps,
+	// also applied when the editor paints them: "blob47", the default,
+	// or "wang16", which draws no inner corners.
+	Autotile string `json:"autotile"`
+
+	// size of a generated map, in tiles
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// sceneCameraDef sets up the main camera of a scene.
+type sceneCameraDef struct {
+	Cyclic     bool `json:"cyclic"`
+	Centralize bool `json:"centralize"`
+
+	// zoom limits, zero for the defaults camZoomMin and camZoomMax
+	MinZoom float64 `json:"minZoom"`
+	MaxZoom float64 `json:"maxZoom"`
+}
+
+// spriteDef places a sprite in a scene.
+type spriteDef struct {
+	Image  string  `json:"image"` // asset file, unless animated by Sheet
+	Sheet  string  `json:"sheet"` // Aseprite JSON data file of an animated sprite
+	Clip   string  `json:"clip"`  // animation clip played first, defaults to the first one
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Center bool    `json:"center"` // at the center of the tilemap, ignoring x,y
+	Angle  float64 `json:"angle"`  // degrees
+	Player bool    `json:"player"` // steered by the player, followed by the camera
+}
+
+// sceneInputs are the input profiles of scenes, by name.
+var sceneInputs = map[string]func(sc *scene){
+	"play":  sceneUpdateInputDefault,
+	"menu":  sceneUpdateInputStart,
+	"pause": sceneUpdateInputPause,
+}
+
+// parseSceneDef decodes and checks a scene file. Unknown fields are
+// rejected, so misspellings do not go unnoticed.
+func parseSceneDef(data []byte) (sceneDef, error) {
+	var def sceneDef
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return def, err
+	}
+	if def.Input == "" {
+		def.Input = "play"
+	}
+	if def.Behavior == "" {
+		def.Behavior = "flight"
+	}
+	return def, def.check()
+}
+
+// check reports the first error in the scene definition.
+func (def sceneDef) check() error {
+	switch def.Map.Generate {
+	case "":
+		if def.Map.File == "" {
+			return errors.New("map: missing file")
+		}
+	case "galaxy", "void":
+		if def.Map.File != "" {
+			return errors.New("map: generated maps have no file")
+		}
+		if _, found := spaceAutotileRules[def.Map.Autotile]; !found && def.Map.Autotile != "" {
+			return fmt.Errorf("map: unknown autotile rules: %q", def.Map.Autotile)
+		}
+		if def.Map.Width <= 0 || def.Map.Height <= 0 {
+			return fmt.Errorf("map: bad size %dx%d", def.Map.Width, def.Map.Height)
+		}
+	default:
+		return fmt.Errorf("map: unknown generator: %q", def.Map.Generate)
+	}
+
+	if _, found := builtinMusic[def.Music]; !found && def.Music != "" {
+		switch strings.ToLower(path.Ext(def.Music)) {
+		case ".mp3", ".ogg":
+		default:
+			return fmt.Errorf("unknown music format: %s", def.Music)
+		}
+	}
+
+	cam := def.Camera
+	if cam.MinZoom < 0 || cam.MaxZoom < 0 ||
+		(cam.MinZoom > 0 && cam.MaxZoom > 0 && cam.MinZoom > cam.MaxZoom) {
+		return fmt.Errorf("camera: bad zoom limits %v..%v", cam.MinZoom, cam.MaxZoom)
+	}
+
+	if _, found := sceneInputs[def.Input]; !found {
+		return fmt.Errorf("unknown input profile: %q", def.Input)
+	}
+
+	if _, found := sceneBehaviors[def.Behavior]; !found {
+		return fmt.Errorf("unknown behavior: %q", def.Behavior)
+	}
+
+	if ov := def.Overlay; ov != nil && (ov.Dim < 0 || ov.Dim > 1) {
+		return fmt.Errorf("overlay: dim out of 0..1: %v", ov.Dim)
+	}
+
+	switch def.Backdrop {
+	case "", "space":
+	default:
+		return fmt.Errorf("unknown backdrop: %q", def.Backdrop)
+	}
+
+	if tr := def.Transition; tr != nil {
+		if _, found := transitionKinds[tr.Kind]; !found {
+			return fmt.Errorf("transition: unknown kind: %q", tr.Kind)
+		}
+		if tr.Seconds < 0 {
+			return fmt.Errorf("transition: negative duration: %v", tr.Seconds)
+		}
+		if _, found := wipeDirections[tr.Direction]; !found && tr.Direction != "" {
+			return fmt.Errorf("transition: unknown direction: %q", tr.Direction)
+		}
+	}
+
+	var players int
+	for i, s := range def.Sprites {
+		if (s.Image == "") == (s.Sheet == "") {
+			return fmt.Errorf("sprite %d: expected either image or sheet", i)
+		}
+		if s.Clip != "" && s.Sheet == "" {
+			return fmt.Errorf("sprite %d: clip without sheet", i)
+		}
+		if s.Player {
+			players++
+		}
+	}
+	if players > 1 {
+		return fmt.Errorf("%d player sprites, expected at most one", players)
+	}
+
+	return nil
+}
+
+// loadSceneDefs loads the scene files in dir of fsys, in file name order.
+func loadSceneDefs(fsys fs.FS, dir string) ([]sceneDef, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no scene files in %s", dir)
+	}
+	defs := make([]sceneDef, 0, len(files))
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		def, err := parseSceneDef(data)
+		if err != nil {
+			return nil, fmt.Errorf("scene file %s: %w", f, err)
+		}
+		if def.Name == "" {
+			def.Name = strings.TrimSuffix(path.Base(f), ".json")
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// findScene returns the index of the first scene with the input profile,
+// or -1 if none.
+func findScene(defs []sceneDef, input string) int {
+	for i, def := range defs {
+		if def.Input == input {
+			return i
+		}
+	}
+	return -1
+}
+
+// sceneOrder returns the indices of the start screen and of the scene to
+// play first.
+func sceneOrder(defs []sceneDef) (start, first int, err error) {
+	start = findScene(defs, "menu")
+	if start < 0 {
+		return 0, 0, errors.New("no start screen: missing scene with menu input")
+	}
+	first = findScene(defs, "play")
+	for i, def := range defs {
+		if def.First {
+			if def.Input != "play" {
+				return 0, 0, fmt.Errorf("scene %d played first, but it is an overlay with %s input", i, def.Input)
+			}
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return 0, 0, errors.New("no scene to play: missing scene with play input")
+	}
+	return start, first, nil
+}
+
+// newScenes builds the scenes described by defs. Scenes loading the
+// same map file share its tiles, so edits show in all of them. The
+// galaxy seed generates the maps and backdrops.
+func (g *game) newScenes(defs []sceneDef, audioContext *audio.Context, seed uint64) ([]*scene, error) {
+	maps := map[string]*tiles{}
+	spriteImages := map[string]*ebiten.Image{}
+	spriteSheets := map[string]*spriteSheet{}
+	var spaceBackdrop func(sc *scene)
+
+	scenes := make([]*scene, 0, len(defs))
+	for _, def := range defs {
+		var ts *tiles
+		rules := spaceRules
+		if r := spaceAutotileRules[def.Map.Autotile]; r != nil {
+			rules = r
+		}
+		switch m := def.Map; m.Generate {
+		case "galaxy":
+			gx := newGalaxy(seed, m.Width, m.Height)
+			gx.rules = rules
+			ts = gx.newTiles(generatedTileSize)
+		case "void":
+			ts = newVoidTiles(generatedTileSize, m.Width, m.Height, rules)
+		default:
+			ts = maps[m.File]
+			if ts == nil {
+				tm, err := loadTiledMap(m.File)
+				if err != nil {
+					return nil, fmt.Errorf("scene %s: %s: %w", def.Name, m.File, err)
+				}
+				if ts, err = newTilesFromTiled(tm); err != nil {
+					return nil, fmt.Errorf("scene %s: %s: %w", def.Name, m.File, err)
+				}
+				ts.mapFile = m.File
+				maps[m.File] = ts
+			}
+		}
+
+		cam := def.Camera
+		sc := newScene(g, ts, def.Music, audioContext, cam.Cyclic, cam.Centralize,
+			def.ShowCoord, sceneOptions{
+				banner:  def.Banner,
+				minZoom: cam.MinZoom,
+				maxZoom: cam.MaxZoom,
+			})
+		sc.name = def.Name
+		sc.input = def.Input
+		sc.overlay = def.Overlay.overlay()
+		sc.behavior = sceneBehaviors[def.Behavior]()
+		sc.transitionIn = def.Transition.style()
+
+		if def.Backdrop == "space" {
+			if spaceBackdrop == nil {
+				spaceBackdrop = newSpaceBackdrop(seed)
+			}
+			spaceBackdrop(sc)
+		}
+
+		for _, s := range def.Sprites {
+			x, y := s.X, s.Y
+			if s.Center {
+				x = float64(ts.tilePixelWidth() / 2)
+				y = float64(ts.tilePixelHeight() / 2)
+			}
+			angle := s.Angle * maxAngle / 360
+
+			var spr *sprite
+			if s.Sheet != "" {
+				sheet := spriteSheets[s.Sheet]
+				if sheet == nil {
+					var err error
+					if sheet, err = loadSpriteSheet(s.Sheet); err != nil {
+						return nil, fmt.Errorf("scene %s: sprite: %s: %w", def.Name, s.Sheet, err)
+					}
+					spriteSheets[s.Sheet] = sheet
+				}
+				anim, err := newAnimation(sheet, s.Clip)
+				if err != nil {
+					return nil, fmt.Errorf("scene %s: sprite: %s: %w", def.Name, s.Sheet, err)
+				}
+				spr = sc.addAnimatedSprite(x, y, angle, anim)
+			} else {
+				img := spriteImages[s.Image]
+				if img == nil {
+					data, err := loadAsset(s.Image)
+					if err != nil {
+						return nil, fmt.Errorf("scene %s: sprite: %w", def.Name, err)
+					}
+					img = createImage(bytes.NewReader(data), 1)
+					spriteImages[s.Image] = img
+				}
+				spr = sc.addSprite(x, y, angle, img)
+			}
+			if s.Player {
+				sc.setPlayer(spr)
+			}
+		}
+
+		scenes = append(scenes, sc)
+	}
+	return scenes, nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+type sceneDefTest struct {
+	name        string
+	data        string
+	expectError bool
+	expectInput string
+}
+
+var sceneDefTestTable = []sceneDefTest{
+	{
+		name:        "tiled map",
+		data:        `{"map": {"file": "sample.tmx"}}`,
+		expectInput: "play",
+	},
+	{
+		name:        "generated map",
+		data:        `{"map": {"generate": "galaxy", "width": 8, "height": 4}, "input": "menu"}`,
+		expectInput: "menu",
+	},
+	{
+		name: "full",
+		data: `{"name": "x", "map": {"file": "a.tmx"}, "music": "ragtime",
+			"camera": {"cyclic": true, "centralize": true, "minZoom": 0.5, "maxZoom": 4},
+			"showCoord": true, "banner": "hi", "first": true, "backdrop": "space",
+			"sprites": [{"image": "a.png", "x": 1, "y": 2, "angle": 90, "player": true},
+				{"image": "a.png", "center": true}]}`,
+		expectInput: "play",
+	},
+	{
+		name:        "music file",
+		data:        `{"map": {"file": "a.tmx"}, "music": "a.OGG"}`,
+		expectInput: "play",
+	},
+	{
+		name:        "transition",
+		data:        `{"map": {"file": "a.tmx"}, "transition": {"kind": "wipe", "seconds": 1, "direction": "up"}}`,
+		expectInput: "play",
+	},
+	{
+		name:        "overlay",
+		data:        `{"map": {"file": "a.tmx"}, "input": "pause", "overlay": {"drawBelow": true, "dim": 0.5, "updateBelow": true}}`,
+		expectInput: "pause",
+	},
+	{
+		name:        "title",
+		data:        `{"map": {"generate": "void", "width": 8, "height": 4}, "input": "menu", "behavior": "title"}`,
+		expectInput: "menu",
+	},
+	{
+		name:        "animated sprite",
+		data:        `{"map": {"file": "a.tmx"}, "sprites": [{"sheet": "a.json", "clip": "idle", "player": true}]}`,
+		expectInput: "play",
+	},
+	{
+		name:        "wang16 galaxy",
+		data:        `{"map": {"generate": "galaxy", "autotile": "wang16", "width": 8, "height": 8}}`,
+		expectInput: "play",
+	},
+	{
+		name:        "bad json",
+		data:        `{"map": `,
+		expectError: true,
+	},
+	{
+		name:        "unknown field",
+		data:        `{"map": {"file": "a.tmx"}, "cyclic": true}`,
+		expectError: true,
+	},
+	{
+		name:        "missing map file",
+		data:        `{"map": {}}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown generator",
+		data:        `{"map": {"generate": "maze", "width": 8, "height": 8}}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown autotile rules",
+		data:        `{"map": {"generate": "galaxy", "autotile": "hex", "width": 8, "height": 8}}`,
+		expectError: true,
+	},
+	{
+		name:        "generated with file",
+		data:        `{"map": {"file": "a.tmx", "generate": "galaxy", "width": 8, "height": 8}}`,
+		expectError: true,
+	},
+	{
+		name:        "generated without size",
+		data:        `{"map": {"generate": "void", "width": 8}}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown music format",
+		data:        `{"map": {"file": "a.tmx"}, "music": "a.wav"}`,
+		expectError: true,
+	},
+	{
+		name:        "bad zoom limits",
+		data:        `{"map": {"file": "a.tmx"}, "camera": {"minZoom": 2, "maxZoom": 1}}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown input",
+		data:        `{"map": {"file": "a.tmx"}, "input": "joystick"}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown backdrop",
+		data:        `{"map": {"file": "a.tmx"}, "backdrop": "forest"}`,
+		expectError: true,
+	},
+	{
+		name:        "sprite without image",
+		data:        `{"map": {"file": "a.tmx"}, "sprites": [{"x": 1}]}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown transition",
+		data:        `{"map": {"file": "a.tmx"}, "transition": {"kind": "spin"}}`,
+		expectError: true,
+	},
+	{
+		name:        "negative transition duration",
+		data:        `{"map": {"file": "a.tmx"}, "transition": {"kind": "fade", "seconds": -1}}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown wipe direction",
+		data:        `{"map": {"file": "a.tmx"}, "transition": {"kind": "wipe", "direction": "in"}}`,
+		expectError: true,
+	},
+	{
+		name:        "unknown behavior",
+		data:        `{"map": {"file": "a.tmx"}, "behavior": "station"}`,
+		expectError: true,
+	},
+	{
+		name:        "overlay dim above 1",
+		data:        `{"map": {"file": "a.tmx"}, "overlay": {"dim": 1.5}}`,
+		expectError: true,
+	},
+	{
+		name:        "sprite with image and sheet",
+		data:        `{"map": {"file": "a.tmx"}, "sprites": [{"image": "a.png", "sheet": "a.json"}]}`,
+		expectError: true,
+	},
+	{
+		name:        "clip without sheet",
+		data:        `{"map": {"file": "a.tmx"}, "sprites": [{"image": "a.png", "clip": "idle"}]}`,
+		expectError: true,
+	},
+	{
+		name: "two players",
+		data: `{"map": {"file": "a.tmx"}, "sprites": [{"image": "a.png", "player": true},
+			{"image": "a.png", "player": true}]}`,
+		expectError: true,
+	},
+}
+
+// go test -count 1 -run '^TestParseSceneDef$' ./...
+func TestParseSceneDef(t *testing.T) {
+	for i, data := range sceneDefTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(sceneDefTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			def, err := parseSceneDef([]byte(data.data))
+			if data.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %+v", def)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if def.Input != data.expectInput {
+				t.Errorf("expected input %q, got %q", data.expectInput, def.Input)
+			}
+		})
+	}
+}
+
+type sceneOrderTest struct {
+	name        string
+	defs        []sceneDef
+	expectStart int
+	expectFirst int
+	expectError bool
+}
+
+var sceneOrderTestTable = []sceneOrderTest{
+	{
+		name:        "start then first",
+		defs:        []sceneDef{{Input: "menu"}, {Input: "play"}, {Input: "play"}},
+		expectStart: 0,
+		expectFirst: 1,
+	},
+	{
+		name:        "start last",
+		defs:        []sceneDef{{Input: "play"}, {Input: "play"}, {Input: "menu"}},
+		expectStart: 2,
+		expectFirst: 0,
+	},
+	{
+		name:        "first marked",
+		defs:        []sceneDef{{Input: "menu"}, {Input: "play"}, {Input: "play", First: true}},
+		expectStart: 0,
+		expectFirst: 2,
+	},
+	{
+		name:        "overlays skipped",
+		defs:        []sceneDef{{Input: "pause"}, {Input: "menu"}, {Input: "menu"}, {Input: "play"}},
+		expectStart: 1,
+		expectFirst: 3,
+	},
+	{
+		name:        "only overlays",
+		defs:        []sceneDef{{Input: "menu"}, {Input: "pause"}},
+		expectError: true,
+	},
+	{
+		name:        "pause played first",
+		defs:        []sceneDef{{Input: "menu"}, {Input: "play"}, {Input: "pause", First: true}},
+		expectError: true,
+	},
+	{
+		name:        "no start screen",
+		defs:        []sceneDef{{Input: "play"}},
+		expectError: true,
+	},
+	{
+		name:        "start played first",
+		defs:        []sceneDef{{Input: "menu", First: true}, {Input: "play"}},
+		expectError: true,
+	},
+	{
+		name:        "only start screen",
+		defs:        []sceneDef{{Input: "menu"}},
+		expectError: true,
+	},
+}
+
+// go test -count 1 -run '^TestSceneOrder$' ./...
+func TestSceneOrder(t *testing.T) {
+	for i, data := range sceneOrderTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(sceneOrderTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			start, first, err := sceneOrder(data.defs)
+			if data.expectError {
+				if err == nil {
+					t.Errorf("expected error, got start=%d first=%d", start, first)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if start != data.expectStart || first != data.expectFirst {
+				t.Errorf("expected start=%d first=%d, got start=%d first=%d",
+					data.expectStart, data.expectFirst, start, first)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestLoadSceneDefs$' ./...
+func TestLoadSceneDefs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"scenes/b.json":  {Data: []byte(`{"map": {"file": "b.tmx"}}`)},
+		"scenes/a.json":  {Data: []byte(`{"name": "alpha", "map": {"file": "a.tmx"}}`)},
+		"scenes/a.txt":   {Data: []byte(`not a scene`)},
+		"bad/x.json":     {Data: []byte(`{"map": {}}`)},
+		"other/c.json":   {Data: []byte(`{"map": {"file": "c.tmx"}}`)},
+		"scenes/sub/d.x": {Data: []byte(``)},
+	}
+
+	defs, err := loadSceneDefs(fsys, "scenes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(defs) != 2 || defs[0].Name != "alpha" || defs[1].Name != "b" {
+		t.Errorf("wrong scenes, expected alpha and b in order: %+v", defs)
+	}
+
+	if _, err := loadSceneDefs(fsys, "bad"); err == nil {
+		t.Errorf("expected error from bad scene file")
+	}
+	if _, err := loadSceneDefs(fsys, "empty"); err == nil {
+		t.Errorf("expected error from directory without scenes")
+	}
+}
+
+// go test -count 1 -run '^TestSceneFiles$' ./...
+func TestSceneFiles(t *testing.T) {
+	defs, err := loadSceneDefs(os.DirFS("../../assets"), sceneDir)
+	if err != nil {
+		t.Fatalf("scene files: %v", err)
+	}
+	if _, _, err := sceneOrder(defs); err != nil {
+		t.Errorf("scene files: %v", err)
+	}
+}
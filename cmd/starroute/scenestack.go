@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// The game runs a stack of scenes. The bottom one is the scene being
+// played, and overlays like the start menu or the pause screen are
+// pushed on top of it. The top scene gets the input, and popping it
+// returns control to the scene below.
+
+// sceneOverlay sets how an overlay scene treats the scenes below it.
+type sceneOverlay struct {
+	drawBelow   bool    // the scene below is drawn under the overlay
+	dim         float64 // alpha of the black drawn over the scene below, in [0,1]
+	updateBelow bool    // the scene below keeps updating
+}
+
+// pushScene pushes the overlay sc on top of the stack. An overlay with
+// its own music silences the music below.
+func (g *game) pushScene(sc *scene) {
+	log.Printf("push scene: %s", sc.name)
+	below := g.getCurrentScene()
+	scenePause(below)
+	if sc.music != "" {
+		below.musicStop()
+	}
+	g.stack = append(g.stack, sc)
+	sc.musicStart()
+	sc.behavior.enter(sc)
+}
+
+// popScene removes the overlay on top of the stack, returning control to
+// the scene below. The scene being played is never popped.
+func (g *game) popScene() {
+	if len(g.stack) < 2 {
+		return
+	}
+	sc := g.getCurrentScene()
+	log.Printf("pop scene: %s", sc.name)
+	sc.behavior.exit(sc)
+	g.stack = g.stack[:len(g.stack)-1]
+	sc.musicStop()
+	below := g.getCurrentScene()
+	if sc.music != "" {
+		below.musicStart()
+	}
+	sceneResume(below)
+}
+
+// visibleScenes returns the scenes of the stack drawn on the screen,
+// bottom to top.
+func (g *game) visibleScenes() []*scene {
+	i := len(g.stack) - 1
+	for i > 0 && g.stack[i].overlay.drawBelow {
+		i--
+	}
+	return g.stack[i:]
+}
+
+// activeScenes returns the scenes of the stack updated each tick, bottom
+// to top.
+func (g *game) activeScenes() []*scene {
+	i := len(g.stack) - 1
+	for i > 0 && g.stack[i].overlay.updateBelow {
+		i--
+	}
+	return g.stack[i:]
+}
+
+// drawStack draws the visible scenes, each overlay over the ones below,
+// dimmed as it sets. It returns the number of tiles drawn.
+func (g *game) drawStack(screen *ebiten.Image, debug bool) int {
+	var countTiles int
+	for i, sc := range g.visibleScenes() {
+		if i > 0 && sc.overlay.dim > 0 {
+			b := screen.Bounds()
+			c := color.NRGBA{0, 0, 0, uint8(min(sc.overlay.dim, 1) * 255)}
+			vector.FillRect(screen, 0, 0, float32(b.Dx()), float32(b.Dy()), c, false)
+		}
+		countTiles += sc.behavior.draw(sc, screen, debug)
+	}
+	return countTiles
+}
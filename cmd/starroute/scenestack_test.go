@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type sceneStackTest struct {
+	name          string
+	overlays      []sceneOverlay // pushed over the scene played, bottom to top
+	expectVisible int            // scenes drawn, counted from the top
+	expectActive  int            // scenes updated, counted from the top
+}
+
+var sceneStackTestTable = []sceneStackTest{
+	{"no overlay", nil, 1, 1},
+	{"opaque overlay", []sceneOverlay{{}}, 1, 1},
+	{"dimmed overlay", []sceneOverlay{{drawBelow: true, dim: 0.5}}, 2, 1},
+	{"live overlay", []sceneOverlay{{drawBelow: true, updateBelow: true}}, 2, 2},
+	{"dialog over live overlay", []sceneOverlay{{drawBelow: true, updateBelow: true}, {drawBelow: true}}, 3, 1},
+	{"opaque over see-through", []sceneOverlay{{drawBelow: true}, {updateBelow: true}}, 1, 2},
+}
+
+// go test -count 1 -run '^TestSceneStack$' ./...
+func TestSceneStack(t *testing.T) {
+	for i, data := range sceneStackTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(sceneStackTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			g := &game{}
+			g.stack = []*scene{{name: "played", behavior: &flightBehavior{}}}
+			for j, ov := range data.overlays {
+				g.pushScene(&scene{name: fmt.Sprintf("overlay%d", j), overlay: ov, behavior: &flightBehavior{}})
+			}
+			if len(g.stack) != len(data.overlays)+1 {
+				t.Fatalf("expected %d scenes on the stack, got %d", len(data.overlays)+1, len(g.stack))
+			}
+			if n := len(g.visibleScenes()); n != data.expectVisible {
+				t.Errorf("expected %d visible scenes, got %d", data.expectVisible, n)
+			}
+			if n := len(g.activeScenes()); n != data.expectActive {
+				t.Errorf("expected %d active scenes, got %d", data.expectActive, n)
+			}
+			top := g.stack[len(g.stack)-1]
+			if g.visibleScenes()[data.expectVisible-1] != top || g.activeScenes()[data.expectActive-1] != top {
+				t.Errorf("expected the top scene last")
+			}
+
+			// pop back down to the scene played, which stays
+			for range len(data.overlays) + 1 {
+				g.popScene()
+			}
+			if len(g.stack) != 1 || g.getCurrentScene().name != "played" {
+				t.Errorf("expected only the scene played left, got %d scenes", len(g.stack))
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestOverlayDrawBelow$' ./...
+func TestOverlayDrawBelow(t *testing.T) {
+	screen := ebiten.NewImage(800, 600)
+	defer screen.Deallocate()
+
+	played := newTestScene(40, false, sceneOptions{})
+	played.behavior = &flightBehavior{}
+	g := played.g
+	g.stack = []*scene{played}
+	below := g.drawStack(screen, false)
+	if below == 0 {
+		t.Fatalf("scene played drew no tiles")
+	}
+
+	// a pause screen over a void map, like assets/scenes/05-pause.json
+	pause := newTestScene(40, false, sceneOptions{})
+	pause.g = g
+	pause.tiles = newVoidTiles(16, 50, 40, spaceRules)
+	pause.overlay = sceneOverlay{drawBelow: true, dim: 0.5}
+	pause.behavior = &flightBehavior{}
+	g.pushScene(pause)
+
+	if got := g.drawStack(screen, false); got != below {
+		t.Errorf("expected only the %d tiles of the scene below drawn, got %d", below, got)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"image"
 	"image/color"
 	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/udhos/starroute/torus"
 )
 
 type sprite struct {
@@ -14,18 +16,91 @@ type sprite struct {
 	angle         float64
 	angleNative   float64 // undo this intrinsic rotate of image to point image to zero angle (right)
 	image         *ebiten.Image
+	anim          *animation // plays the frames of a sprite sheet instead of image, nil if none
+	vx, vy        float64    // velocity in world pixels per second
+	spin          float64    // rotation in angles per second
 }
 
-func (s *sprite) update() {
-	s.angle = math.Mod(s.angle+1, maxAngle)
+// sprite thrust in world pixels per second squared, and top speed in
+// world pixels per second
+const (
+	spriteThrust   = 540
+	spriteMaxSpeed = 480
+	spriteDrag     = 0.3 // velocity kept after one second without thrust
+)
+
+// spriteSpin is the rotation of the sprites not steered by the player, in
+// angles per second.
+const spriteSpin = 60
+
+// update advances the sprite by one tick of dt seconds.
+func (s *sprite) update(dt float64) {
+	s.angle = math.Mod(s.angle+s.spin*dt, maxAngle)
+	s.x += s.vx * dt
+	s.y += s.vy * dt
+	if s.anim != nil {
+		s.anim.update(dt)
+	}
+}
+
+// animateSteering plays the thrust clip while the sprite accelerates, or
+// the idle clip, when the sheet has them. A clip played a number of
+// times, like an explosion, is not cut short.
+func (s *sprite) animateSteering(thrust bool) {
+	if s.anim == nil || (!s.anim.looping() && !s.anim.done) {
+		return
+	}
+	if thrust {
+		s.anim.play("thrust")
+	} else {
+		s.anim.play("idle")
+	}
+}
+
+// steer accelerates the sprite for dt seconds towards the direction
+// ax,ay, or lets it drift to a stop when both are zero. The sprite faces
+// its velocity.
+func (s *sprite) steer(ax, ay, dt float64) {
+	if ax == 0 && ay == 0 {
+		drag := math.Pow(spriteDrag, dt)
+		s.vx *= drag
+		s.vy *= drag
+	} else {
+		n := math.Hypot(ax, ay)
+		s.vx += spriteThrust * dt * ax / n
+		s.vy += spriteThrust * dt * ay / n
+	}
+	s.limitSpeed(spriteMaxSpeed)
+	if s.vx != 0 || s.vy != 0 {
+		s.angle = math.Mod(math.Atan2(s.vy, s.vx)*maxAngle/pi2+maxAngle, maxAngle)
+	}
+}
+
+// limitSpeed slows the sprite down to maxSpeed, keeping its direction.
+func (s *sprite) limitSpeed(maxSpeed float64) {
+	if speed := math.Hypot(s.vx, s.vy); speed > maxSpeed {
+		s.vx *= maxSpeed / speed
+		s.vy *= maxSpeed / speed
+	}
+}
+
+// wrap keeps the sprite position within the world.
+func (s *sprite) wrap(world torus.Torus) {
+	s.x, s.y = world.Normalize(s.x, s.y)
 }
 
 func (s *sprite) draw(op ebiten.DrawImageOptions, screen *ebiten.Image, camX, camY float64, debug bool) {
 
-	w, h := s.image.Bounds().Dx(), s.image.Bounds().Dy()
+	img := s.image
+	if s.anim != nil {
+		f := s.anim.frame()
+		img = f.image
+		// place a trimmed frame within the full frame
+		op.GeoM.Translate(float64(f.offsetX), float64(f.offsetY))
+	}
 
-	centerX := float64(w) / 2
-	centerY := float64(h) / 2
+	centerX := float64(s.width) / 2
+	centerY := float64(s.height) / 2
 
 	// move the rotation center to the origin
 	op.GeoM.Translate(-centerX, -centerY)
@@ -49,7 +124,7 @@ func (s *sprite) draw(op ebiten.DrawImageOptions, screen *ebiten.Image, camX, ca
 	// apply the actual object's position
 	op.GeoM.Translate(s.x-camX, s.y-camY)
 
-	screen.DrawImage(s.image, &op)
+	screen.DrawImage(img, &op)
 
 	if debug {
 		x := s.x + centerX - camX
@@ -91,3 +166,21 @@ func drawDebugArrow(screen *ebiten.Image, x, y, angle, lenght, width float64, ar
 	drawOp.AntiAlias = false
 	vector.StrokePath(screen, &path, strokeOp, drawOp)
 }
+
+// bounds returns the world rectangle covered by the sprite, ignoring rotation.
+func (s *sprite) bounds() image.Rectangle {
+	return s.boundsAt(s.x, s.y)
+}
+
+// boundsAt returns the world rectangle the sprite would cover at x,y.
+func (s *sprite) boundsAt(x, y float64) image.Rectangle {
+	left, top := int(math.Floor(x)), int(math.Floor(y))
+	return image.Rect(left, top, left+s.width, top+s.height)
+}
+
+// contains reports whether the sprite covers the world position x,y,
+// ignoring rotation. The sprite wraps around the edges of the world.
+func (s *sprite) contains(x, y float64, world torus.Torus) bool {
+	return world.Contains(torus.Rect{X: s.x, Y: s.y,
+		Width: float64(s.width), Height: float64(s.height)}, x, y)
+}
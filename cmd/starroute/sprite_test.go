@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// go test -count 1 -run '^TestSpriteSteer$' ./...
+func TestSpriteSteer(t *testing.T) {
+	// half a second of thrust, then one second of drift
+	const expectSpeed = spriteThrust / 2 * spriteDrag
+	for _, tps := range []int{30, 60, 120} {
+		dt := 1 / float64(tps)
+		spr := &sprite{}
+		for range tps / 2 {
+			spr.steer(1, 0, dt)
+			spr.update(dt)
+		}
+		for range tps {
+			spr.steer(0, 0, dt)
+			spr.update(dt)
+		}
+		if math.Abs(spr.vx-expectSpeed) > 1e-9 || spr.vy != 0 {
+			t.Errorf("tps=%d: wrong velocity: expected %v,0 got %v,%v", tps, expectSpeed, spr.vx, spr.vy)
+		}
+		// the distance flown depends slightly on the tick rate
+		if expectX := 225.0; math.Abs(spr.x-expectX) > 2 {
+			t.Errorf("tps=%d: wrong position: expected about %v got %v", tps, expectX, spr.x)
+		}
+	}
+}
+
+// go test -count 1 -run '^TestSpriteMaxSpeed$' ./...
+func TestSpriteMaxSpeed(t *testing.T) {
+	spr := &sprite{}
+	for range 120 {
+		spr.steer(1, 1, 1.0/60)
+	}
+	if speed := math.Hypot(spr.vx, spr.vy); math.Abs(speed-spriteMaxSpeed) > 1e-9 {
+		t.Errorf("wrong top speed: expected %v got %v", spriteMaxSpeed, speed)
+	}
+}
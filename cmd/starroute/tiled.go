@@ -0,0 +1,685 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// tiledMap is the subset of a Tiled map used by the game.
+// It is produced from either the TMX (XML) or the TMJ (JSON) format.
+// See https://doc.mapeditor.org/en/stable/reference/tmx-map-format/
+type tiledMap struct {
+	width, height         int // in tiles
+	tileWidth, tileHeight int // in pixels
+	tilesets              []tiledTileset
+	layers                []tiledLayer
+	properties            map[string]string
+	dir                   string // directory of the map within assets
+}
+
+// tiledTileset is a tileset referenced by a Tiled map, either embedded in
+// the map or loaded from an external TSX/TSJ file.
+type tiledTileset struct {
+	firstGID              int
+	name                  string
+	tileWidth, tileHeight int
+	tileCount, columns    int
+	margin, spacing       int
+	image                 string                    // asset path, already resolved relative to the map
+	source                string                    // external tileset file as referenced by the map, empty if embedded
+	animations            map[int][]tileFrame       // key is local tile index
+	properties            map[int]map[string]string // key is local tile index
+}
+
+// addFrame appends an animation frame to the tile id.
+func (t *tiledTileset) addFrame(id, frameTileID, durationMs int) {
+	if t.animations == nil {
+		t.animations = map[int][]tileFrame{}
+	}
+	t.animations[id] = append(t.animations[id], tileFrame{
+		index:    frameTileID,
+		duration: time.Duration(durationMs) * time.Millisecond,
+	})
+}
+
+// setProperties sets the custom properties of the tile id.
+func (t *tiledTileset) setProperties(id int, props map[string]string) {
+	if len(props) == 0 {
+		return
+	}
+	if t.properties == nil {
+		t.properties = map[int]map[string]string{}
+	}
+	t.properties[id] = props
+}
+
+// tiledLayer is a tile layer of a Tiled map.
+// data holds one global tile ID per cell, row by row.
+type tiledLayer struct {
+	name          string
+	width, height int
+	visible       bool
+	data          []uint32
+	properties    map[string]string
+}
+
+// newTilesFromTiled creates tiles from a Tiled map.
+// Layers keep the global tile IDs from the map, including the flip flags,
+// which are resolved into the map tilesets at draw time.
+func newTilesFromTiled(tm *tiledMap) (*tiles, error) {
+	if tm.tileWidth != tm.tileHeight {
+		return nil, fmt.Errorf("tiles must be square: %dx%d", tm.tileWidth, tm.tileHeight)
+	}
+	if len(tm.tilesets) == 0 {
+		return nil, fmt.Errorf("map has no tilesets")
+	}
+	if len(tm.layers) == 0 {
+		return nil, fmt.Errorf("map has no tile layers")
+	}
+
+	layers := make([][]int, 0, len(tm.layers))
+	for _, l := range tm.layers {
+		if len(l.data) != tm.width*tm.height {
+			return nil, fmt.Errorf("layer %q has %d tiles, expected %dx%d",
+				l.name, len(l.data), tm.width, tm.height)
+		}
+		layer := make([]int, len(l.data))
+		for i, gid := range l.data {
+			layer[i] = int(gid)
+		}
+		layers = append(layers, layer)
+	}
+
+	ts := newTilesFromLayers(tm.tileWidth, layers, tm.width)
+	ts.properties = tm.properties
+	for i, l := range tm.layers {
+		ts.layerInfo[i] = tileLayerInfo{
+			name:       l.name,
+			visible:    l.visible,
+			properties: l.properties,
+		}
+	}
+
+	// tilesets may share the same sprite sheet
+	images := map[string]*ebiten.Image{}
+
+	for _, set := range tm.tilesets {
+		img, found := images[set.image]
+		if !found {
+			data, err := loadAsset(set.image)
+			if err != nil {
+				return nil, err
+			}
+			decoded, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("tileset image %s: %w", set.image, err)
+			}
+			img = ebiten.NewImageFromImage(decoded)
+			images[set.image] = img
+		}
+		tset := newTileset(set.name, img, set.firstGID,
+			set.tileWidth, set.tileHeight, set.margin, set.spacing,
+			set.columns, set.tileCount)
+		for id, frames := range set.animations {
+			tset.addAnimation(id, frames)
+		}
+		for id, props := range set.properties {
+			tset.setProps(id, parseTileProps(props))
+		}
+		ts.tilesets.add(tset)
+		ts.tmxTilesets = append(ts.tmxTilesets, set.tmx(tm.dir))
+	}
+
+	return ts, nil
+}
+
+// loadTiledMap loads a Tiled map from assets.
+// The format is chosen by file extension: .tmx for XML, .tmj or .json for JSON.
+func loadTiledMap(filename string) (*tiledMap, error) {
+	data, err := loadAsset(filename)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(filename)
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".tmx":
+		return parseTMX(data, dir, loadAsset)
+	case ".tmj", ".json":
+		return parseTMJ(data, dir, loadAsset)
+	}
+	return nil, fmt.Errorf("unknown tiled map format: %s", filename)
+}
+
+// tiledLoader loads external files referenced by a map, like tilesets.
+type tiledLoader func(filename string) ([]byte, error)
+
+//
+// TMX (XML) format
+//
+
+type tmxMap struct {
+	XMLName     xml.Name      `xml:"map"`
+	Version     string        `xml:"version,attr,omitempty"`
+	Orientation string        `xml:"orientation,attr"`
+	Width       int           `xml:"width,attr"`
+	Height      int           `xml:"height,attr"`
+	TileWidth   int           `xml:"tilewidth,attr"`
+	TileHeight  int           `xml:"tileheight,attr"`
+	Infinite    int           `xml:"infinite,attr"`
+	Properties  []tmxProperty `xml:"properties>property"`
+	Tilesets    []tmxTileset  `xml:"tileset"`
+	Layers      []tmxLayer    `xml:"layer"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"` // multiline string values
+}
+
+type tmxTileset struct {
+	FirstGID   int       `xml:"firstgid,attr,omitempty"`
+	Source     string    `xml:"source,attr,omitempty"`
+	Name       string    `xml:"name,attr,omitempty"`
+	TileWidth  int       `xml:"tilewidth,attr,omitempty"`
+	TileHeight int       `xml:"tileheight,attr,omitempty"`
+	Spacing    int       `xml:"spacing,attr,omitempty"`
+	Margin     int       `xml:"margin,attr,omitempty"`
+	TileCount  int       `xml:"tilecount,attr,omitempty"`
+	Columns    int       `xml:"columns,attr,omitempty"`
+	Image      *tmxImage `xml:"image"`
+	Tiles      []tmxTile `xml:"tile"`
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+}
+
+type tmxTile struct {
+	ID         int           `xml:"id,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Animation  []tmxFrame    `xml:"animation>frame"`
+}
+
+type tmxFrame struct {
+	TileID   int `xml:"tileid,attr"`
+	Duration int `xml:"duration,attr"` // milliseconds
+}
+
+type tmxLayer struct {
+	Name       string        `xml:"name,attr"`
+	Width      int           `xml:"width,attr"`
+	Height     int           `xml:"height,attr"`
+	Visible    *int          `xml:"visible,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Data       struct {
+		Encoding    string `xml:"encoding,attr,omitempty"`
+		Compression string `xml:"compression,attr,omitempty"`
+		Text        string `xml:",chardata"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+	} `xml:"data"`
+}
+
+func tmxProperties(list []tmxProperty) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	props := map[string]string{}
+	for _, p := range list {
+		v := p.Value
+		if v == "" {
+			v = p.Text
+		}
+		props[p.Name] = v
+	}
+	return props
+}
+
+func (t tmxTileset) tileset(dir string) tiledTileset {
+	set := tiledTileset{
+		firstGID:   t.FirstGID,
+		name:       t.Name,
+		tileWidth:  t.TileWidth,
+		tileHeight: t.TileHeight,
+		tileCount:  t.TileCount,
+		columns:    t.Columns,
+		margin:     t.Margin,
+		spacing:    t.Spacing,
+	}
+	if t.Image != nil {
+		set.image = path.Join(dir, t.Image.Source)
+	}
+	for _, tile := range t.Tiles {
+		for _, f := range tile.Animation {
+			set.addFrame(tile.ID, f.TileID, f.Duration)
+		}
+		set.setProperties(tile.ID, tmxProperties(tile.Properties))
+	}
+	return set
+}
+
+func parseTMX(data []byte, dir string, load tiledLoader) (*tiledMap, error) {
+	var m tmxMap
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Orientation != "" && m.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported map orientation: %s", m.Orientation)
+	}
+	if m.Infinite != 0 {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+
+	tm := &tiledMap{
+		width:      m.Width,
+		height:     m.Height,
+		tileWidth:  m.TileWidth,
+		tileHeight: m.TileHeight,
+		properties: tmxProperties(m.Properties),
+		dir:        dir,
+	}
+
+	for _, t := range m.Tilesets {
+		if t.Source == "" {
+			tm.tilesets = append(tm.tilesets, t.tileset(dir))
+			continue
+		}
+		set, err := loadTiledTileset(path.Join(dir, t.Source), load)
+		if err != nil {
+			return nil, err
+		}
+		set.firstGID = t.FirstGID
+		set.source = t.Source
+		tm.tilesets = append(tm.tilesets, set)
+	}
+
+	for _, l := range m.Layers {
+		layer := tiledLayer{
+			name:       l.Name,
+			width:      l.Width,
+			height:     l.Height,
+			visible:    l.Visible == nil || *l.Visible != 0,
+			properties: tmxProperties(l.Properties),
+		}
+		if l.Data.Encoding == "" {
+			// no encoding: one <tile gid=""> element per cell
+			for _, t := range l.Data.Tiles {
+				layer.data = append(layer.data, t.GID)
+			}
+		} else {
+			gids, err := decodeTiledData(l.Data.Encoding, l.Data.Compression, l.Data.Text)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+			}
+			layer.data = gids
+		}
+		tm.layers = append(tm.layers, layer)
+	}
+
+	return tm, nil
+}
+
+// marshalTMX encodes the tiles as a TMX map with CSV layers.
+func (ts *tiles) marshalTMX() ([]byte, error) {
+	if len(ts.tmxTilesets) == 0 {
+		return nil, fmt.Errorf("no tilesets to save")
+	}
+
+	m := tmxMap{
+		Version:     "1.10",
+		Orientation: "orthogonal",
+		Width:       ts.tileLayerXCount,
+		Height:      ts.tileLayerYCount,
+		TileWidth:   ts.tileSize,
+		TileHeight:  ts.tileSize,
+		Properties:  tmxPropertyList(ts.properties),
+		Tilesets:    ts.tmxTilesets,
+	}
+
+	for li, info := range ts.layerInfo {
+		l := tmxLayer{
+			Name:       info.name,
+			Width:      ts.tileLayerXCount,
+			Height:     ts.tileLayerYCount,
+			Properties: tmxPropertyList(info.properties),
+		}
+		if !info.visible {
+			hidden := 0
+			l.Visible = &hidden
+		}
+		l.Data.Encoding = "csv"
+
+		var sb strings.Builder
+		sb.WriteString("\n")
+		for row := range ts.tileLayerYCount {
+			for col := range ts.tileLayerXCount {
+				sb.WriteString(strconv.FormatUint(uint64(uint32(ts.tileAt(li, col, row))), 10))
+				if col < ts.tileLayerXCount-1 || row < ts.tileLayerYCount-1 {
+					sb.WriteByte(',')
+				}
+			}
+			sb.WriteString("\n")
+		}
+		l.Data.Text = sb.String()
+
+		m.Layers = append(m.Layers, l)
+	}
+
+	data, err := xml.MarshalIndent(m, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(xml.Header), data...), '\n'), nil
+}
+
+// tmx returns the tileset as written in a TMX map within the directory dir.
+func (t tiledTileset) tmx(dir string) tmxTileset {
+	if t.source != "" {
+		return tmxTileset{FirstGID: t.firstGID, Source: t.source}
+	}
+
+	set := tmxTileset{
+		FirstGID:   t.firstGID,
+		Name:       t.name,
+		TileWidth:  t.tileWidth,
+		TileHeight: t.tileHeight,
+		Spacing:    t.spacing,
+		Margin:     t.margin,
+		TileCount:  t.tileCount,
+		Columns:    t.columns,
+	}
+	if t.image != "" {
+		image := t.image
+		if dir != "." {
+			image = strings.TrimPrefix(image, dir+"/")
+		}
+		set.Image = &tmxImage{Source: image}
+	}
+
+	var ids []int
+	for id := range t.animations {
+		ids = append(ids, id)
+	}
+	for id := range t.properties {
+		if _, found := t.animations[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		tile := tmxTile{ID: id, Properties: tmxPropertyList(t.properties[id])}
+		for _, f := range t.animations[id] {
+			tile.Animation = append(tile.Animation, tmxFrame{
+				TileID:   f.index,
+				Duration: int(f.duration / time.Millisecond),
+			})
+		}
+		set.Tiles = append(set.Tiles, tile)
+	}
+
+	return set
+}
+
+// tmxPropertyList converts properties to TMX, sorted by name.
+func tmxPropertyList(props map[string]string) []tmxProperty {
+	var list []tmxProperty
+	for name, value := range props {
+		list = append(list, tmxProperty{Name: name, Value: value})
+	}
+	slices.SortFunc(list, func(a, b tmxProperty) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return list
+}
+
+//
+// TMJ (JSON) format
+//
+
+type tmjMap struct {
+	Orientation string        `json:"orientation"`
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	TileWidth   int           `json:"tilewidth"`
+	TileHeight  int           `json:"tileheight"`
+	Infinite    bool          `json:"infinite"`
+	Properties  []tmjProperty `json:"properties"`
+	Tilesets    []tmjTileset  `json:"tilesets"`
+	Layers      []tmjLayer    `json:"layers"`
+}
+
+type tmjProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type tmjTileset struct {
+	FirstGID   int    `json:"firstgid"`
+	Source     string `json:"source"`
+	Name       string `json:"name"`
+	TileWidth  int    `json:"tilewidth"`
+	TileHeight int    `json:"tileheight"`
+	Spacing    int    `json:"spacing"`
+	Margin     int    `json:"margin"`
+	TileCount  int    `json:"tilecount"`
+	Columns    int    `json:"columns"`
+	Image      string `json:"image"`
+	Tiles      []struct {
+		ID         int           `json:"id"`
+		Properties []tmjProperty `json:"properties"`
+		Animation  []struct {
+			TileID   int `json:"tileid"`
+			Duration int `json:"duration"` // milliseconds
+		} `json:"animation"`
+	} `json:"tiles"`
+}
+
+type tmjLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Visible     *bool           `json:"visible"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Data        json.RawMessage `json:"data"`
+	Properties  []tmjProperty   `json:"properties"`
+}
+
+func tmjProperties(list []tmjProperty) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	props := map[string]string{}
+	for _, p := range list {
+		props[p.Name] = fmt.Sprint(p.Value)
+	}
+	return props
+}
+
+func (t tmjTileset) tileset(dir string) tiledTileset {
+	set := tiledTileset{
+		firstGID:   t.FirstGID,
+		name:       t.Name,
+		tileWidth:  t.TileWidth,
+		tileHeight: t.TileHeight,
+		tileCount:  t.TileCount,
+		columns:    t.Columns,
+		margin:     t.Margin,
+		spacing:    t.Spacing,
+		image:      path.Join(dir, t.Image),
+	}
+	for _, tile := range t.Tiles {
+		for _, f := range tile.Animation {
+			set.addFrame(tile.ID, f.TileID, f.Duration)
+		}
+		set.setProperties(tile.ID, tmjProperties(tile.Properties))
+	}
+	return set
+}
+
+func parseTMJ(data []byte, dir string, load tiledLoader) (*tiledMap, error) {
+	var m tmjMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Orientation != "" && m.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported map orientation: %s", m.Orientation)
+	}
+	if m.Infinite {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+
+	tm := &tiledMap{
+		width:      m.Width,
+		height:     m.Height,
+		tileWidth:  m.TileWidth,
+		tileHeight: m.TileHeight,
+		properties: tmjProperties(m.Properties),
+		dir:        dir,
+	}
+
+	for _, t := range m.Tilesets {
+		if t.Source == "" {
+			tm.tilesets = append(tm.tilesets, t.tileset(dir))
+			continue
+		}
+		set, err := loadTiledTileset(path.Join(dir, t.Source), load)
+		if err != nil {
+			return nil, err
+		}
+		set.firstGID = t.FirstGID
+		set.source = t.Source
+		tm.tilesets = append(tm.tilesets, set)
+	}
+
+	for _, l := range m.Layers {
+		if l.Type != "tilelayer" {
+			continue // object, image and group layers are ignored
+		}
+		layer := tiledLayer{
+			name:       l.Name,
+			width:      l.Width,
+			height:     l.Height,
+			visible:    l.Visible == nil || *l.Visible,
+			properties: tmjProperties(l.Properties),
+		}
+		if l.Encoding == "base64" {
+			var s string
+			if err := json.Unmarshal(l.Data, &s); err != nil {
+				return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+			}
+			gids, err := decodeTiledData(l.Encoding, l.Compression, s)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+			}
+			layer.data = gids
+		} else if err := json.Unmarshal(l.Data, &layer.data); err != nil {
+			return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+		}
+		tm.layers = append(tm.layers, layer)
+	}
+
+	return tm, nil
+}
+
+// loadTiledTileset loads an external tileset.
+// The format is chosen by file extension: .tsx for XML, .tsj or .json for JSON.
+func loadTiledTileset(filename string, load tiledLoader) (tiledTileset, error) {
+	data, err := load(filename)
+	if err != nil {
+		return tiledTileset{}, err
+	}
+	dir := path.Dir(filename)
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".tsx":
+		var t tmxTileset
+		if err := xml.Unmarshal(data, &t); err != nil {
+			return tiledTileset{}, fmt.Errorf("tileset %s: %w", filename, err)
+		}
+		return t.tileset(dir), nil
+	case ".tsj", ".json":
+		var t tmjTileset
+		if err := json.Unmarshal(data, &t); err != nil {
+			return tiledTileset{}, fmt.Errorf("tileset %s: %w", filename, err)
+		}
+		return t.tileset(dir), nil
+	}
+	return tiledTileset{}, fmt.Errorf("unknown tiled tileset format: %s", filename)
+}
+
+// decodeTiledData decodes the global tile IDs of a layer.
+// encoding is either csv or base64.
+// compression is only used for base64: empty (uncompressed), zlib or gzip.
+func decodeTiledData(encoding, compression, data string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var gids []uint32
+		for _, f := range strings.Split(data, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("bad csv tile: %w", err)
+			}
+			gids = append(gids, uint32(gid))
+		}
+		return gids, nil
+	case "base64":
+	default:
+		return nil, fmt.Errorf("unsupported layer encoding: %s", encoding)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("bad base64 data: %w", err)
+	}
+
+	var r io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		r, err = zlib.NewReader(r)
+	case "gzip":
+		r, err = gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported layer compression: %s", compression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad %s data: %w", compression, err)
+	}
+
+	raw, err = io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("bad %s data: %w", compression, err)
+	}
+	if len(raw)%4 != 0 {
+		return nil, fmt.Errorf("bad layer data size: %d", len(raw))
+	}
+
+	gids := make([]uint32, len(raw)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(raw[4*i:])
+	}
+	return gids, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func encodeGIDs(gids []uint32, compression string) string {
+	raw := make([]byte, 4*len(gids))
+	for i, gid := range gids {
+		binary.LittleEndian.PutUint32(raw[4*i:], gid)
+	}
+	var buf bytes.Buffer
+	switch compression {
+	case "zlib":
+		w := zlib.NewWriter(&buf)
+		w.Write(raw)
+		w.Close()
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		w.Write(raw)
+		w.Close()
+	default:
+		buf.Write(raw)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+type tiledDataTest struct {
+	name        string
+	encoding    string
+	compression string
+	data        string
+	expectGIDs  []uint32
+	expectError bool
+}
+
+var tiledDataTestTable = []tiledDataTest{
+	{
+		name:       "csv",
+		encoding:   "csv",
+		data:       "\n1,2,3,\n0,0,2147483649\n",
+		expectGIDs: []uint32{1, 2, 3, 0, 0, 2147483649},
+	},
+	{
+		name:       "base64 uncompressed",
+		encoding:   "base64",
+		data:       encodeGIDs([]uint32{5, 0, 7}, ""),
+		expectGIDs: []uint32{5, 0, 7},
+	},
+	{
+		name:        "base64 zlib",
+		encoding:    "base64",
+		compression: "zlib",
+		data:        encodeGIDs([]uint32{1, 2, 3, 4}, "zlib"),
+		expectGIDs:  []uint32{1, 2, 3, 4},
+	},
+	{
+		name:        "base64 gzip",
+		encoding:    "base64",
+		compression: "gzip",
+		data:        encodeGIDs([]uint32{9, 8}, "gzip"),
+		expectGIDs:  []uint32{9, 8},
+	},
+	{
+		name:        "csv bad tile",
+		encoding:    "csv",
+		data:        "1,x,3",
+		expectError: true,
+	},
+	{
+		name:        "base64 unsupported compression",
+		encoding:    "base64",
+		compression: "zstd",
+		data:        encodeGIDs([]uint32{1}, ""),
+		expectError: true,
+	},
+	{
+		name:        "unsupported encoding",
+		encoding:    "hex",
+		data:        "01",
+		expectError: true,
+	},
+}
+
+// go test -count 1 -run '^TestDecodeTiledData$' ./...
+func TestDecodeTiledData(t *testing.T) {
+	for i, data := range tiledDataTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(tiledDataTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			gids, err := decodeTiledData(data.encoding, data.compression, data.data)
+			if data.expectError {
+				if err == nil {
+					t.Errorf("expected error, got gids %v", gids)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(gids, data.expectGIDs) {
+				t.Errorf("wrong gids: expected %v got %v", data.expectGIDs, gids)
+			}
+		})
+	}
+}
+
+const testTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="3" height="2" tilewidth="16" tileheight="16" infinite="0">
+ <properties>
+  <property name="music" value="track1"/>
+ </properties>
+ <tileset firstgid="1" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+  <image source="images/terrain.png" width="32" height="32"/>
+ </tileset>
+ <layer id="1" name="ground" width="3" height="2">
+  <properties>
+   <property name="parallax" type="float" value="0.5"/>
+  </properties>
+  <data encoding="csv">
+1,2,3,
+4,1,2
+</data>
+ </layer>
+ <layer id="2" name="hidden" width="3" height="2" visible="0">
+  <data>
+   <tile gid="1"/><tile/><tile/>
+   <tile/><tile/><tile gid="4"/>
+  </data>
+ </layer>
+</map>
+`
+
+const testTMJ = `{
+ "orientation": "orthogonal", "width": 2, "height": 2,
+ "tilewidth": 8, "tileheight": 8, "infinite": false,
+ "tilesets": [{"firstgid": 10, "source": "sets/space.tsj"}],
+ "layers": [
+  {"type": "tilelayer", "name": "stars", "width": 2, "height": 2, "visible": true,
+   "data": [10, 0, 0, 11],
+   "properties": [{"name": "static", "type": "bool", "value": true}]},
+  {"type": "objectgroup", "name": "objects"},
+  {"type": "tilelayer", "name": "nebula", "width": 2, "height": 2, "visible": false,
+   "encoding": "base64", "compression": "zlib", "data": "eJxjZGBgYAJiZiBmAWIAAGAACw=="}
+ ]
+}`
+
+const testTSJ = `{"name": "space", "tilewidth": 8, "tileheight": 8,
+ "tilecount": 4, "columns": 2, "margin": 1, "spacing": 2, "image": "../images/space.png",
+ "tiles": [{"id": 1, "properties": [{"name": "slow", "type": "float", "value": 0.25}]}]}`
+
+func testTiledLoader(files map[string]string) tiledLoader {
+	return func(filename string) ([]byte, error) {
+		data, found := files[filename]
+		if !found {
+			return nil, fmt.Errorf("file not found: %s", filename)
+		}
+		return []byte(data), nil
+	}
+}
+
+// go test -count 1 -run '^TestParseTMX$' ./...
+func TestParseTMX(t *testing.T) {
+	tm, err := parseTMX([]byte(testTMX), "maps", testTiledLoader(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tm.width != 3 || tm.height != 2 || tm.tileWidth != 16 || tm.tileHeight != 16 {
+		t.Errorf("wrong map size: %+v", tm)
+	}
+	if tm.properties["music"] != "track1" {
+		t.Errorf("wrong map properties: %v", tm.properties)
+	}
+	if len(tm.tilesets) != 1 {
+		t.Fatalf("wrong tileset count: %d", len(tm.tilesets))
+	}
+	set := tm.tilesets[0]
+	if set.firstGID != 1 || set.columns != 2 || set.image != "maps/images/terrain.png" {
+		t.Errorf("wrong tileset: %+v", set)
+	}
+	if len(tm.layers) != 2 {
+		t.Fatalf("wrong layer count: %d", len(tm.layers))
+	}
+	ground := tm.layers[0]
+	if ground.name != "ground" || !ground.visible || ground.properties["parallax"] != "0.5" {
+		t.Errorf("wrong ground layer: %+v", ground)
+	}
+	if !slices.Equal(ground.data, []uint32{1, 2, 3, 4, 1, 2}) {
+		t.Errorf("wrong ground data: %v", ground.data)
+	}
+	hidden := tm.layers[1]
+	if hidden.visible {
+		t.Errorf("hidden layer should not be visible")
+	}
+	if !slices.Equal(hidden.data, []uint32{1, 0, 0, 0, 0, 4}) {
+		t.Errorf("wrong hidden data: %v", hidden.data)
+	}
+}
+
+// go test -count 1 -run '^TestParseTMJ$' ./...
+func TestParseTMJ(t *testing.T) {
+	load := testTiledLoader(map[string]string{"maps/sets/space.tsj": testTSJ})
+	tm, err := parseTMJ([]byte(testTMJ), "maps", load)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tm.tilesets) != 1 {
+		t.Fatalf("wrong tileset count: %d", len(tm.tilesets))
+	}
+	set := tm.tilesets[0]
+	if set.firstGID != 10 || set.margin != 1 || set.spacing != 2 || set.image != "maps/images/space.png" {
+		t.Errorf("wrong tileset: %+v", set)
+	}
+	if set.properties[1]["slow"] != "0.25" {
+		t.Errorf("wrong tile properties: %v", set.properties)
+	}
+	if len(tm.layers) != 2 {
+		t.Fatalf("wrong layer count: %d", len(tm.layers))
+	}
+	stars := tm.layers[0]
+	if !slices.Equal(stars.data, []uint32{10, 0, 0, 11}) {
+		t.Errorf("wrong stars data: %v", stars.data)
+	}
+	if stars.properties["static"] != "true" {
+		t.Errorf("wrong stars properties: %v", stars.properties)
+	}
+	nebula := tm.layers[1]
+	if nebula.visible {
+		t.Errorf("nebula layer should not be visible")
+	}
+	if !slices.Equal(nebula.data, []uint32{1, 2, 3, 4}) {
+		t.Errorf("wrong nebula data: %v", nebula.data)
+	}
+}
+
+// go test -count 1 -run '^TestParseSampleTMX$' ./...
+func TestParseSampleTMX(t *testing.T) {
+	load := func(filename string) ([]byte, error) {
+		return os.ReadFile("../../assets/" + filename)
+	}
+	data, err := load("sample.tmx")
+	if err != nil {
+		t.Fatalf("read sample map: %v", err)
+	}
+	tm, err := parseTMX(data, ".", load)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tm.tilesets) != 1 || tm.tilesets[0].image != "tiles.png" {
+		t.Fatalf("wrong tilesets: %+v", tm.tilesets)
+	}
+	if frames := tm.tilesets[0].animations[303]; len(frames) != 2 || frames[1].duration != 500*time.Millisecond {
+		t.Errorf("wrong animation: %+v", frames)
+	}
+	// generated maps must agree with the tileset
+	for gid, expect := range spaceTileProps {
+		if got := parseTileProps(tm.tilesets[0].properties[gid-1]); got != expect {
+			t.Errorf("tile %d: wrong properties: expected %v got %v", gid, expect, got)
+		}
+	}
+	for _, l := range tm.layers {
+		if len(l.data) != tm.width*tm.height {
+			t.Errorf("layer %q: wrong tile count: %d", l.name, len(l.data))
+		}
+	}
+}
+
+// go test -count 1 -run '^TestMarshalTMX$' ./...
+func TestMarshalTMX(t *testing.T) {
+	ts := newTestTiles(4, singleTileSource(tileSpace), singleTileSource(tileEmpty))
+	ts.tmxTilesets = []tmxTileset{{FirstGID: 1, Source: "tiles.tsx"}}
+	ts.properties = map[string]string{"seed": "42"}
+	ts.layerInfo[1].name = "station"
+	ts.layerInfo[1].visible = false
+	ts.setTile(1, 3, 2, flipTile(tileStar, tileFlipHorizontal))
+
+	data, err := ts.marshalTMX()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	load := testTiledLoader(map[string]string{"tiles.tsx": `<tileset name="tiles"
+ tilewidth="16" tileheight="16" tilecount="350" columns="25"><image source="tiles.png"/></tileset>`})
+	tm, err := parseTMX(data, ".", load)
+	if err != nil {
+		t.Fatalf("parse saved map: %v\n%s", err, data)
+	}
+	if tm.width != 4 || tm.height != 4 || tm.tileWidth != 16 {
+		t.Errorf("wrong map size: %+v", tm)
+	}
+	if tm.properties["seed"] != "42" {
+		t.Errorf("wrong map properties: %v", tm.properties)
+	}
+	if len(tm.tilesets) != 1 || tm.tilesets[0].source != "tiles.tsx" || tm.tilesets[0].firstGID != 1 {
+		t.Errorf("wrong tilesets: %+v", tm.tilesets)
+	}
+	if len(tm.layers) != 2 {
+		t.Fatalf("wrong layer count: %d", len(tm.layers))
+	}
+	if tm.layers[0].name != "layer 1" || !tm.layers[0].visible || tm.layers[0].data[0] != tileSpace {
+		t.Errorf("wrong first layer: %+v", tm.layers[0])
+	}
+	station := tm.layers[1]
+	if station.name != "station" || station.visible {
+		t.Errorf("wrong second layer: %+v", station)
+	}
+	if got := station.data[2*4+3]; got != tileStar|tileFlipHorizontal {
+		t.Errorf("wrong flipped tile: got %d", got)
+	}
+
+	// embedded tilesets keep their animations and properties
+	set := tiledTileset{firstGID: 1, name: "space", tileWidth: 8, tileHeight: 8,
+		tileCount: 4, columns: 2, image: "maps/space.png"}
+	set.addFrame(1, 2, 100)
+	set.setProperties(3, map[string]string{"solid": "true"})
+	embedded := set.tmx("maps").tileset("maps")
+	if embedded.image != "maps/space.png" || len(embedded.animations[1]) != 1 ||
+		embedded.properties[3]["solid"] != "true" {
+		t.Errorf("wrong embedded tileset: %+v", embedded)
+	}
+}
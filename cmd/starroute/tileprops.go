@@ -0,0 +1,189 @@
+package main
+
+import (
+	"image"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/udhos/starroute/torus"
+)
+
+// tileProps are the gameplay properties of a tile.
+// They are loaded from the custom properties of the tiles in a Tiled
+// tileset, using the property names below.
+type tileProps struct {
+	solid   bool    // "solid": blocks movement
+	hazard  bool    // "hazard": damages ships
+	dock    bool    // "dock": ships can dock
+	mineral string  // "mineral": name of the mineral deposit, empty if none
+	slow    float64 // "slow": speed factor in (0,1), 0 if not slowed
+}
+
+// parseTileProps converts the custom properties of a tile.
+// Unknown property names are ignored, since they may be used by tools.
+func parseTileProps(props map[string]string) tileProps {
+	var p tileProps
+	for name, value := range props {
+		switch name {
+		case "solid":
+			p.solid = parseTileBool(value)
+		case "hazard":
+			p.hazard = parseTileBool(value)
+		case "dock":
+			p.dock = parseTileBool(value)
+		case "mineral":
+			p.mineral = value
+		case "slow":
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 && f < 1 {
+				p.slow = f
+			}
+		}
+	}
+	return p
+}
+
+func parseTileBool(s string) bool {
+	b, _ := strconv.ParseBool(strings.TrimSpace(s))
+	return b
+}
+
+// merge combines the properties of tiles stacked in several layers.
+// Flags add up, the strongest slow wins and the top mineral is kept.
+func (p tileProps) merge(top tileProps) tileProps {
+	p.solid = p.solid || top.solid
+	p.hazard = p.hazard || top.hazard
+	p.dock = p.dock || top.dock
+	if top.mineral != "" {
+		p.mineral = top.mineral
+	}
+	if top.slow > 0 && (p.slow == 0 || top.slow < p.slow) {
+		p.slow = top.slow
+	}
+	return p
+}
+
+// String lists the properties for debugging, like "solid,slow=0.5".
+func (p tileProps) String() string {
+	var list []string
+	if p.solid {
+		list = append(list, "solid")
+	}
+	if p.hazard {
+		list = append(list, "hazard")
+	}
+	if p.dock {
+		list = append(list, "dock")
+	}
+	if p.mineral != "" {
+		list = append(list, "mineral="+p.mineral)
+	}
+	if p.slow > 0 {
+		list = append(list, "slow="+strconv.FormatFloat(p.slow, 'f', -1, 64))
+	}
+	return strings.Join(list, ",")
+}
+
+// setTileProps sets the properties of the tiles, keyed by global tile ID.
+func (ts *tiles) setTileProps(props map[int]tileProps) {
+	for gid, p := range props {
+		set, index := ts.tilesets.resolve(gid)
+		if set == nil {
+			log.Printf("setTileProps: unknown global tile ID: %d", gid)
+			continue
+		}
+		set.setProps(index, p)
+	}
+}
+
+// tileHit is a tile overlapped by a query rectangle.
+type tileHit struct {
+	col, row int
+	props    tileProps
+}
+
+// propsAt returns the properties of the tiles of all visible layers at
+// column col and row.
+func (ts *tiles) propsAt(col, row int) tileProps {
+	var p tileProps
+	for li, info := range ts.layerInfo {
+		if !info.visible {
+			continue
+		}
+		gid := ts.tileAt(li, col, row) & tileGIDMask
+		if gid == tileEmpty {
+			continue
+		}
+		set, index := ts.tilesets.resolve(gid)
+		if set == nil {
+			continue
+		}
+		p = p.merge(set.properties[index])
+	}
+	return p
+}
+
+// propsAtPixel returns the properties of the tiles at world pixel x,y.
+// If wrap is true, the pixel wraps around the tilemap edges, as seen by a
+// cyclic camera. Otherwise found is false beyond the edges.
+func (ts *tiles) propsAtPixel(x, y int, wrap bool) (props tileProps, found bool) {
+	hits := ts.overlapping(image.Rect(x, y, x+1, y+1), wrap)
+	if len(hits) == 0 {
+		return tileProps{}, false
+	}
+	return hits[0].props, true
+}
+
+// overlapping returns the tiles overlapped by the world rectangle r.
+// If wrap is true, parts of r beyond the tilemap edges hit the tiles at the
+// opposite edges, as seen by a cyclic camera. Otherwise they are ignored.
+// A rectangle larger than the tilemap hits each tile once.
+func (ts *tiles) overlapping(r image.Rectangle, wrap bool) []tileHit {
+	if r.Empty() {
+		return nil
+	}
+	colMin := floorDiv(r.Min.X, ts.tileSize)
+	rowMin := floorDiv(r.Min.Y, ts.tileSize)
+	colMax := floorDiv(r.Max.X-1, ts.tileSize)
+	rowMax := floorDiv(r.Max.Y-1, ts.tileSize)
+	cells := torus.Rect{X: float64(colMin), Y: float64(rowMin),
+		Width: float64(colMax - colMin + 1), Height: float64(rowMax - rowMin + 1)}
+
+	// the world in tiles: flat, with the cells clipped to the tilemap,
+	// or wrapping around the tilemap edges
+	tilemap := torus.Rect{Width: float64(ts.tileLayerXCount), Height: float64(ts.tileLayerYCount)}
+	var world torus.Torus
+	if wrap {
+		world = torus.New(tilemap.Width, tilemap.Height)
+	}
+
+	var hits []tileHit
+	for _, part := range world.Intersect(cells, tilemap) {
+		for row := int(part.Y); row < int(part.Y+part.Height); row++ {
+			for col := int(part.X); col < int(part.X+part.Width); col++ {
+				hits = append(hits, tileHit{col: col, row: row, props: ts.propsAt(col, row)})
+			}
+		}
+	}
+	return hits
+}
+
+// overlappingProps returns the merged properties of the tiles overlapped
+// by the world rectangle r. See overlapping.
+func (ts *tiles) overlappingProps(r image.Rectangle, wrap bool) tileProps {
+	var p tileProps
+	for _, hit := range ts.overlapping(r, wrap) {
+		p = p.merge(hit.props)
+	}
+	return p
+}
+
+// floorDiv divides rounding towards negative infinity,
+// so negative world pixels fall in negative tiles.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
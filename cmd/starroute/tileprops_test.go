@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"testing"
+)
+
+type parseTilePropsTest struct {
+	name   string
+	props  map[string]string
+	expect tileProps
+}
+
+var parseTilePropsTestTable = []parseTilePropsTest{
+	{
+		name:   "none",
+		expect: tileProps{},
+	},
+	{
+		name:   "flags",
+		props:  map[string]string{"solid": "true", "hazard": "false", "dock": "1"},
+		expect: tileProps{solid: true, dock: true},
+	},
+	{
+		name:   "mineral and slow",
+		props:  map[string]string{"mineral": "Technetium-99m", "slow": "0.25"},
+		expect: tileProps{mineral: "Technetium-99m", slow: 0.25},
+	},
+	{
+		name:   "slow out of range",
+		props:  map[string]string{"slow": "2"},
+		expect: tileProps{},
+	},
+	{
+		name:   "unknown ignored",
+		props:  map[string]string{"color": "red"},
+		expect: tileProps{},
+	},
+}
+
+// go test -count 1 -run '^TestParseTileProps$' ./...
+func TestParseTileProps(t *testing.T) {
+	for i, data := range parseTilePropsTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(parseTilePropsTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := parseTileProps(data.props); got != data.expect {
+				t.Errorf("wrong properties: expected %v got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+// newTestPropsTiles creates 40x40 tiles of space with an asteroid at
+// column 0 row 0 and a nebula over space at column 39 row 39.
+func newTestPropsTiles() *tiles {
+	ts := newTestTiles(40, singleTileSource(tileSpace), singleTileSource(tileEmpty))
+	ts.setTileProps(spaceTileProps)
+	ts.setTile(1, 0, 0, tileAsteroid)
+	ts.setTile(1, 39, 39, tileNebula)
+	return ts
+}
+
+type propsAtPixelTest struct {
+	name        string
+	x, y        int
+	wrap        bool
+	expectFound bool
+	expect      tileProps
+}
+
+var propsAtPixelTestTable = []propsAtPixelTest{
+	{
+		name: "asteroid",
+		x:    15, y: 0,
+		expectFound: true,
+		expect:      spaceTileProps[tileAsteroid],
+	},
+	{
+		name: "plain space",
+		x:    16, y: 0,
+		expectFound: true,
+	},
+	{
+		name: "beyond edge",
+		x:    -1, y: -1,
+	},
+	{
+		name: "beyond edge wraps to nebula",
+		x:    -1, y: -1,
+		wrap:        true,
+		expectFound: true,
+		expect:      spaceTileProps[tileNebula],
+	},
+	{
+		name: "past world size wraps to asteroid",
+		x:    640, y: 640,
+		wrap:        true,
+		expectFound: true,
+		expect:      spaceTileProps[tileAsteroid],
+	},
+}
+
+// go test -count 1 -run '^TestPropsAtPixel$' ./...
+func TestPropsAtPixel(t *testing.T) {
+	ts := newTestPropsTiles()
+	for i, data := range propsAtPixelTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(propsAtPixelTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			got, found := ts.propsAtPixel(data.x, data.y, data.wrap)
+			if found != data.expectFound {
+				t.Fatalf("wrong found: expected %t got %t", data.expectFound, found)
+			}
+			if got != data.expect {
+				t.Errorf("wrong properties: expected %v got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+type overlappingTest struct {
+	name       string
+	rect       image.Rectangle
+	wrap       bool
+	expectHits int
+	expect     tileProps
+}
+
+var overlappingTestTable = []overlappingTest{
+	{
+		name:       "single tile",
+		rect:       image.Rect(1, 1, 15, 15),
+		expectHits: 1,
+		expect:     spaceTileProps[tileAsteroid],
+	},
+	{
+		name:       "four tiles",
+		rect:       image.Rect(8, 8, 24, 24),
+		expectHits: 4,
+		expect:     spaceTileProps[tileAsteroid],
+	},
+	{
+		name:       "clipped at edge",
+		rect:       image.Rect(-8, -8, 8, 8),
+		expectHits: 1,
+		expect:     spaceTileProps[tileAsteroid],
+	},
+	{
+		name:       "across the seam",
+		rect:       image.Rect(-8, -8, 8, 8),
+		wrap:       true,
+		expectHits: 4,
+		expect:     spaceTileProps[tileAsteroid].merge(spaceTileProps[tileNebula]),
+	},
+	{
+		name:       "larger than the world",
+		rect:       image.Rect(-100, 0, 1000, 16),
+		wrap:       true,
+		expectHits: 40,
+		expect:     spaceTileProps[tileAsteroid],
+	},
+}
+
+// go test -count 1 -run '^TestOverlapping$' ./...
+func TestOverlapping(t *testing.T) {
+	ts := newTestPropsTiles()
+	for i, data := range overlappingTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(overlappingTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if hits := ts.overlapping(data.rect, data.wrap); len(hits) != data.expectHits {
+				t.Errorf("wrong hits: expected %d got %d: %v", data.expectHits, len(hits), hits)
+			}
+			if got := ts.overlappingProps(data.rect, data.wrap); got != data.expect {
+				t.Errorf("wrong properties: expected %v got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+type reactToTerrainTest struct {
+	name             string
+	x, y             float64
+	vx, vy           float64
+	expectX, expectY float64
+	expectSpeed      float64
+	expectShake      bool
+}
+
+var reactToTerrainTestTable = []reactToTerrainTest{
+	{
+		name: "open space",
+		x:    100, y: 100, vx: 128,
+		expectX: 104, expectY: 100, expectSpeed: 128,
+	},
+	{
+		name: "blocked by asteroid",
+		x:    0, y: 18, vy: -128,
+		expectX: 0, expectY: 18,
+	},
+	{
+		name: "off an asteroid",
+		x:    0, y: 0, vy: 256,
+		expectX: 0, expectY: 8, expectSpeed: 256,
+	},
+	{
+		name: "from an asteroid onto the next",
+		x:    0, y: 0, vx: 256,
+		expectX: 0, expectY: 0,
+	},
+	{
+		name: "braked by nebula",
+		x:    616, y: 624, vx: 256,
+		expectX: 624, expectY: 624, expectSpeed: spriteMaxSpeed * 0.5,
+	},
+	{
+		name: "shaken by star cluster",
+		x:    300, y: 320, vx: 256,
+		expectX: 308, expectY: 320, expectSpeed: 256, expectShake: true,
+	},
+}
+
+// go test -count 1 -run '^TestReactToTerrain$' ./...
+func TestReactToTerrain(t *testing.T) {
+	for i, data := range reactToTerrainTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(reactToTerrainTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			sc := newTestScene(40, false, sceneOptions{})
+			sc.tiles = newTestPropsTiles()
+			sc.tiles.setTile(1, 20, 20, tileStarCluster)
+			sc.tiles.setTile(1, 1, 0, tileAsteroid)
+			sc.player = &sprite{x: data.x, y: data.y, vx: data.vx, vy: data.vy, width: 16, height: 16}
+			sc.player.update(1.0 / 32) // 8 pixels at 256 pixels per second
+			sc.reactToTerrain(data.x, data.y)
+			spr := sc.player
+			if spr.x != data.expectX || spr.y != data.expectY {
+				t.Errorf("wrong position: expected %v,%v got %v,%v", data.expectX, data.expectY, spr.x, spr.y)
+			}
+			if speed := math.Hypot(spr.vx, spr.vy); speed != data.expectSpeed {
+				t.Errorf("wrong speed: expected %v got %v", data.expectSpeed, speed)
+			}
+			if shaken := sc.cam.fx.trauma > 0; shaken != data.expectShake {
+				t.Errorf("wrong shake: expected %t got %t", data.expectShake, shaken)
+			}
+		})
+	}
+}
@@ -1,34 +1,82 @@
 package main
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"io"
 	"log"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/udhos/starroute/torus"
 )
 
+// tileEmpty is the global tile ID of an empty cell, which is not drawn.
+const tileEmpty = 0
+
+// The high bits of a global tile ID are flags that flip the tile, like
+// in Tiled maps. Diagonal flip is applied first, then horizontal, then
+// vertical, so rotations are expressed as combinations of flips.
+// The flags are tested on the ID as uint32, since the horizontal flag
+// does not fit a 32-bit int.
+const (
+	tileFlipHorizontal uint32 = 0x80000000
+	tileFlipVertical   uint32 = 0x40000000
+	tileFlipDiagonal   uint32 = 0x20000000
+	tileGIDMask               = 0x0fffffff // clears the flag bits
+)
+
+// tiles is a tilemap with several layers of global tile IDs.
+// Tiles are stored in chunks, loaded from the source on demand and evicted
+// when unused, so the world size is not bounded by memory.
 type tiles struct {
-	tilesImage      *ebiten.Image
+	tilesets        tilesetRegistry
 	tileSize        int
-	layers          [][]int
+	layerInfo       []tileLayerInfo
 	tileLayerXCount int
+	tileLayerYCount int
+	properties      map[string]string
+	clock           time.Duration // drives tile animations
+	tick            int           // update count, used to evict unused chunks
+	source          chunkSource
+	chunks          map[int]*tileChunk
+	noChunkCache    bool           // draw every tile individually
+	mapFile         string         // asset the tilemap is saved to
+	generated       bool           // regenerated at every start, so never saved
+	terrain         *autotileRules // autotile the first layer when edited, nil if none
+	seeThrough      int            // global tile ID not drawn, to show the parallax behind, or tileEmpty
+	tmxTilesets     []tmxTileset   // tilesets as written in saved maps
+	version         int            // incremented by every tile change
+}
+
+// tileLayerInfo holds the metadata of a tile layer.
+type tileLayerInfo struct {
+	name       string
+	visible    bool
+	properties map[string]string
 }
 
 func (ts tiles) tilePixelDimensions() (int, int) {
 	return ts.tilePixelWidth(), ts.tilePixelHeight()
 }
 
+// world returns the tilemap as a world wrapping around its edges, in
+// pixels.
+func (ts tiles) world() torus.Torus {
+	return torus.New(float64(ts.tilePixelWidth()), float64(ts.tilePixelHeight()))
+}
+
 func (ts tiles) tilePixelWidth() int {
 	return ts.tileSize * ts.tileLayerXCount
 }
 
 func (ts tiles) tilePixelHeight() int {
-	return ts.tileSize * len(ts.layers[0]) / ts.tileLayerXCount
+	return ts.tileSize * ts.tileLayerYCount
 }
 
-func newTiles(r io.Reader, tileSize int, layers [][]int, tileLayerXCount int) *tiles {
+func newTiles(r io.Reader, tileSize, layerCount, tileLayerXCount, tileLayerYCount int,
+	source chunkSource) *tiles {
 
 	// Decode an image from the image file's byte slice.
 	img, _, err := image.Decode(r)
@@ -36,31 +84,61 @@ func newTiles(r io.Reader, tileSize int, layers [][]int, tileLayerXCount int) *t
 		log.Fatal(err)
 	}
 
+	ts := newTilesFromSource(tileSize, layerCount, tileLayerXCount, tileLayerYCount, source)
+
+	// layer value 0 is empty, then tile index i in the image is global tile ID i+1
+	const firstGID = 1
+	ts.tilesets.add(newTileset("tiles", ebiten.NewImageFromImage(img), firstGID,
+		tileSize, tileSize, 0, 0, 0, 0))
+
+	return ts
+}
+
+// newTilesFromLayers creates tiles from whole layers held in memory.
+// The caller must add the tilesets referenced by the layers.
+func newTilesFromLayers(tileSize int, layers [][]int, tileLayerXCount int) *tiles {
+	tileLayerYCount := len(layers[0]) / tileLayerXCount
+	return newTilesFromSource(tileSize, len(layers), tileLayerXCount, tileLayerYCount,
+		layerSource(layers, tileLayerXCount))
+}
+
+// newTilesFromSource creates tiles whose chunks are provided by source.
+// The caller must add the tilesets referenced by the layers.
+func newTilesFromSource(tileSize, layerCount, tileLayerXCount, tileLayerYCount int,
+	source chunkSource) *tiles {
+
 	ts := &tiles{
-		tilesImage:      ebiten.NewImageFromImage(img),
 		tileSize:        tileSize,
-		layers:          layers,
+		layerInfo:       make([]tileLayerInfo, layerCount),
 		tileLayerXCount: tileLayerXCount,
+		tileLayerYCount: tileLayerYCount,
+		source:          source,
+		chunks:          map[int]*tileChunk{},
 	}
 
-	log.Printf("Tile size: %d", tileSize)
+	for i := range ts.layerInfo {
+		ts.layerInfo[i].name = fmt.Sprintf("layer %d", i+1)
+		ts.layerInfo[i].visible = true
+	}
 
-	log.Printf("Tiles image size: %dx%d", ts.tilesImage.Bounds().Dx(), ts.tilesImage.Bounds().Dy())
+	log.Printf("Tile size: %d", tileSize)
 
-	log.Printf("Tile layer X count: %d", tileLayerXCount)
+	log.Printf("Tile layer count: %dx%d", tileLayerXCount, tileLayerYCount)
 
 	dimX, dimY := ts.tilePixelDimensions()
 
 	log.Printf("Tile layer size: %dx%d", dimX, dimY)
 
-	tilesImageXCount := ts.tilesImage.Bounds().Dx() / tileSize
-	tilesImageYCount := ts.tilesImage.Bounds().Dy() / tileSize
-
-	log.Printf("Tiles image has %dx%d tiles", tilesImageXCount, tilesImageYCount)
-
 	return ts
 }
 
+// update advances tile animations by one tick and evicts unused chunks.
+func (ts *tiles) update() {
+	ts.clock += time.Second / time.Duration(ebiten.TPS())
+	ts.tick++
+	ts.evictChunks()
+}
+
 // quad represents one of the four quadrants needed to draw with a cyclic camera.
 type quad struct {
 	draw bool
@@ -74,6 +152,14 @@ type quad struct {
 	width, height int
 }
 
+// origin returns the world position the quadrant places at the top-left
+// corner of the screen. It is the camera position of the quadrant, which
+// may lie outside the tilemap. Drawing subtracts it from world positions:
+// screen = world - worldX + camOffsetX.
+func (q quad) origin() (float64, float64) {
+	return float64(q.worldX - q.camOffsetX), float64(q.worldY - q.camOffsetY)
+}
+
 // getQuadrants returns the four quadrants needed to draw with a cyclic camera.
 //
 // world size is given by tilemap size in pixels: tilePixelWidth(), tilePixelHeight()
@@ -91,54 +177,30 @@ type quad struct {
 // quadrant 4: when part of the view is beyond both the right and bottom edges of the tilemap
 func (ts *tiles) getQuadrants(cam *camera, screenWidth, screenHeight int) [4]quad {
 
-	tilemapWidth := ts.tilePixelWidth()
-	tilemapHeight := ts.tilePixelHeight()
+	// quadrants are cut at whole pixels, so they meet without seams
+	camX, camY, _, _ := cam.origin()
 
-	tilemapWidthMinusCam := tilemapWidth - cam.x
-	tilemapHeightMinusCam := tilemapHeight - cam.y
+	parts := ts.world().Split(torus.Rect{X: float64(camX), Y: float64(camY),
+		Width: float64(screenWidth), Height: float64(screenHeight)})
 
-	widthQuads1and3 := min(screenWidth, tilemapWidthMinusCam)
-	heightQuads1and2 := min(screenHeight, tilemapHeightMinusCam)
-	widthQuads2and4 := screenWidth - tilemapWidthMinusCam
-	heightQuads3and4 := screenHeight - tilemapHeightMinusCam
-
-	drawQuadrant2 := screenWidth > tilemapWidthMinusCam
-	drawQuadrant3 := screenHeight > tilemapHeightMinusCam
-	drawQuadrant4 := drawQuadrant2 && drawQuadrant3
-
-	return [4]quad{
-		// quadrant 1: top-left
-		{
+	// quadrants top-left, top-right, bottom-left and bottom-right
+	var quads [4]quad
+	for _, p := range parts {
+		i := 0
+		if p.OffsetX > 0 {
+			i++
+		}
+		if p.OffsetY > 0 {
+			i += 2
+		}
+		quads[i] = quad{
 			draw:       true,
-			camOffsetX: 0, camOffsetY: 0,
-			worldX: cam.x, worldY: cam.y,
-			width: widthQuads1and3, height: heightQuads1and2,
-		},
-
-		// quadrant 2: top-right
-		{
-			draw:       drawQuadrant2,
-			camOffsetX: widthQuads1and3, camOffsetY: 0,
-			worldX: 0, worldY: cam.y,
-			width: widthQuads2and4, height: heightQuads1and2,
-		},
-
-		// quadrant 3: bottom-left
-		{
-			draw:       drawQuadrant3,
-			camOffsetX: 0, camOffsetY: heightQuads1and2,
-			worldX: cam.x, worldY: 0,
-			width: widthQuads1and3, height: heightQuads3and4,
-		},
-
-		// quadrant 4: bottom-right
-		{
-			draw:       drawQuadrant4,
-			camOffsetX: widthQuads1and3, camOffsetY: heightQuads1and2,
-			worldX: 0, worldY: 0,
-			width: widthQuads2and4, height: heightQuads3and4,
-		},
+			camOffsetX: int(p.OffsetX), camOffsetY: int(p.OffsetY),
+			worldX: int(p.X), worldY: int(p.Y),
+			width: int(p.Width), height: int(p.Height),
+		}
 	}
+	return quads
 }
 
 func (ts *tiles) draw(screen *ebiten.Image, cam *camera, debug bool, quads *[4]quad) int {
@@ -193,8 +255,10 @@ func (ts *tiles) draw(screen *ebiten.Image, cam *camera, debug bool, quads *[4]q
 		const camOffsetX = 0
 		const camOffsetY = 0
 
+		camX, camY, _, _ := cam.origin()
+
 		sum = ts.drawQuadrant(screen,
-			cam.x, cam.y,
+			camX, camY,
 			screenWidth, screenHeight,
 			camOffsetX, camOffsetY)
 
@@ -211,6 +275,9 @@ func (ts *tiles) draw(screen *ebiten.Image, cam *camera, debug bool, quads *[4]q
 	return sum
 }
 
+// drawQuadrant draws the world window at worldX,worldY of size width x height
+// into the screen at camOffsetX,camOffsetY.
+// It returns the number of DrawImage calls issued.
 func (ts *tiles) drawQuadrant(screen *ebiten.Image,
 	worldX, worldY,
 	width, height,
@@ -218,42 +285,31 @@ func (ts *tiles) drawQuadrant(screen *ebiten.Image,
 
 	var sum int
 
-	tileSize := ts.tileSize
-	xCount := ts.tileLayerXCount
-	tileImageXCount := ts.tilesImage.Bounds().Dx() / tileSize
+	window := image.Rect(worldX, worldY, worldX+width, worldY+height)
 
-	for _, l := range ts.layers {
-		offset, xAmount, yAmount := findTilemapWindow(len(l), ts.tileLayerXCount, ts.tileSize,
-			worldX, worldY, width, height)
+	// dx,dy translates world coordinates into screen coordinates.
+	// We translate by -worldX and -worldY to account for the camera
+	// position, and add the quadrant camera offset so this quadrant
+	// is drawn at the correct place on the screen when wrapping.
+	dx := camOffsetX - worldX
+	dy := camOffsetY - worldY
 
-		i := offset
+	chunksX, chunksY := ts.chunkCounts()
+	offset, xAmount, yAmount := findTilemapWindow(chunksX*chunksY, chunksX,
+		tileChunkSize*ts.tileSize, worldX, worldY, width, height)
+
+	for li := range ts.layerInfo {
+		if !ts.layerInfo[li].visible {
+			continue
+		}
+
+		c := offset
 		for range yAmount {
 			for range xAmount {
-				t := l[i]
-
-				op := &ebiten.DrawImageOptions{}
-				// screenX,screenY is the position on the screen where the tile must be drawn
-				// i % xCount gives the column of the tile in the tile layer
-				// i / xCount gives the row of the tile in the tile layer
-				// We translate by -worldX and -worldY to account for the camera
-				// position, and add the quadrant camera offset so this quadrant
-				// is drawn at the correct place on the screen when wrapping.
-				screenX := (i % xCount) * tileSize
-				screenY := (i / xCount) * tileSize
-				op.GeoM.Translate(float64(screenX-worldX+camOffsetX), float64(screenY-worldY+camOffsetY))
-
-				// sx,sy is the position within the tiles image where the tile graphic is located
-				// t % tileImageXCount gives the column of the tile in the tiles image
-				// t / tileImageXCount gives the row of the tile in the tiles image
-				sx := (t % tileImageXCount) * tileSize
-				sy := (t / tileImageXCount) * tileSize
-				subImage := ts.tilesImage.SubImage(image.Rect(sx, sy, sx+tileSize, sy+tileSize)).(*ebiten.Image)
-				screen.DrawImage(subImage, op)
-
-				sum++
-				i++
+				sum += ts.drawChunk(screen, li, c, window, dx, dy)
+				c++
 			}
-			i += xCount - xAmount
+			c += chunksX - xAmount
 		}
 	}
 
@@ -267,46 +323,62 @@ func (ts *tiles) drawQuadrant(screen *ebiten.Image,
 	return sum
 }
 
-var sampleLayers = [][]int{
-	{
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 218, 243, 243, 243, 243, 243, 243, 243, 243, 243, 218, 243, 244, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 244, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 219, 243, 243, 243, 219, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-		243, 218, 243, 243, 243, 243, 243, 243, 243, 243, 243, 244, 243, 243, 243,
-		243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243, 243,
-	},
-
-	{
-		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 26, 27, 28, 29, 30, 31, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 51, 52, 53, 54, 55, 56, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 76, 77, 78, 79, 80, 81, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 101, 102, 103, 104, 105, 106, 0, 0, 0, 0,
-
-		0, 0, 0, 0, 0, 126, 127, 128, 129, 130, 131, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 303, 303, 245, 242, 303, 303, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 245, 242, 0, 0, 0, 0, 0, 0,
-	},
+// hidden reports whether the global tile ID is not drawn: an empty cell
+// or the see-through tile.
+func (ts *tiles) hidden(gid int) bool {
+	return gid == tileEmpty || gid&tileGIDMask == ts.seeThrough
+}
+
+// drawTile draws the global tile ID into the cell with top-left corner at x,y of dst.
+// It returns false if nothing was drawn because the tile is hidden or unknown.
+func (ts *tiles) drawTile(dst *ebiten.Image, gid, x, y int) bool {
+	if ts.hidden(gid) {
+		return false
+	}
+	set, t := ts.tilesets.resolve(gid & tileGIDMask)
+	if set == nil {
+		// unknown tile
+		return false
+	}
+	t = set.animatedTile(t, ts.clock)
+
+	op := &ebiten.DrawImageOptions{}
+	tileFlipGeoM(&op.GeoM, gid, set.tileWidth, set.tileHeight)
+
+	// Tiles taller than the cell are aligned to the cell bottom, like Tiled does.
+	y += ts.tileSize - set.tileHeight
+	op.GeoM.Translate(float64(x), float64(y))
+
+	// t is the index of the tile graphic within the tileset image
+	dst.DrawImage(set.tileImage(t), op)
+
+	return true
+}
+
+// tileFlipGeoM applies the flip flags of the global tile ID to geom.
+// width and height are the tile dimensions. The flipped tile is kept
+// at the same origin.
+func tileFlipGeoM(geom *ebiten.GeoM, gid, width, height int) {
+	flags := uint32(gid)
+	w, h := float64(width), float64(height)
+	if flags&tileFlipDiagonal != 0 {
+		// swap x and y axes
+		var transpose ebiten.GeoM
+		transpose.SetElement(0, 0, 0)
+		transpose.SetElement(0, 1, 1)
+		transpose.SetElement(1, 0, 1)
+		transpose.SetElement(1, 1, 0)
+		geom.Concat(transpose)
+		w, h = h, w
+	}
+	if flags&tileFlipHorizontal != 0 {
+		geom.Scale(-1, 1)
+		geom.Translate(w, 0)
+	}
+	if flags&tileFlipVertical != 0 {
+		geom.Scale(1, -1)
+		geom.Translate(0, h)
+	}
 }
 
 // findTilemapWindow finds within a layer, composed of layerTiles tiles with
@@ -321,17 +393,22 @@ var sampleLayers = [][]int{
 // The caller must then draw tileXAmount tiles starting from tileOffset,
 // then skip tileXAmount-layerTileWidth tiles, and so forth,
 // up to tileYAmount rows.
+// The world is queried in two steps: first over the grid of chunks, with
+// tilePixelWidth as the chunk size in pixels, to find the chunks within the
+// window; then over the tiles of each chunk.
 func findTilemapWindow(layerTiles, layerTileWidth, tilePixelWidth,
 	winX, winY, winWidth, winHeight int) (tileOffset, tileXAmount,
 	tileYAmount int) {
-	// Calculate the starting column and row
-	startCol := winX / tilePixelWidth
-	startRow := winY / tilePixelWidth
+	// Calculate the starting column and row.
+	// Windows may start above or left of the layer, as when a cyclic
+	// view spills over the edges, so round down and clip at zero.
+	startCol := max(floorDiv(winX, tilePixelWidth), 0)
+	startRow := max(floorDiv(winY, tilePixelWidth), 0)
 
 	// Calculate the ending column and row (inclusive)
 	// We subtract 1 from the sum to get the last pixel, then divide
-	endCol := (winX + winWidth - 1) / tilePixelWidth
-	endRow := (winY + winHeight - 1) / tilePixelWidth
+	endCol := floorDiv(winX+winWidth-1, tilePixelWidth)
+	endRow := floorDiv(winY+winHeight-1, tilePixelWidth)
 
 	// Calculate the number of tiles in each dimension
 	tileXAmount = endCol - startCol + 1
@@ -350,6 +427,8 @@ func findTilemapWindow(layerTiles, layerTileWidth, tilePixelWidth,
 	if tileYAmount > layerTileHeight-startRow {
 		tileYAmount = layerTileHeight - startRow
 	}
+	tileXAmount = max(tileXAmount, 0)
+	tileYAmount = max(tileYAmount, 0)
 
 	// Calculate the linear offset of the first tile
 	tileOffset = startRow*layerTileWidth + startCol
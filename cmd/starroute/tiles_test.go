@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
 )
 
 type tyleTest struct {
@@ -126,6 +128,32 @@ var tyleTestTable = []tyleTest{
 		expectTileXAmount: 1,
 		expectTileYAmount: 3,
 	},
+	{
+		name:              "window starting above and left of 4x4 map",
+		layerTiles:        16,
+		layerTileWidth:    4,
+		tilePixelWidth:    8,
+		winX:              -3,
+		winY:              -9,
+		winWidth:          12,
+		winHeight:         18,
+		expectTileOffset:  0,
+		expectTileXAmount: 2,
+		expectTileYAmount: 2,
+	},
+	{
+		name:              "window entirely left of 4x4 map",
+		layerTiles:        16,
+		layerTileWidth:    4,
+		tilePixelWidth:    8,
+		winX:              -20,
+		winY:              0,
+		winWidth:          10,
+		winHeight:         8,
+		expectTileOffset:  0,
+		expectTileXAmount: 0,
+		expectTileYAmount: 1,
+	},
 }
 
 // go test -count 1 -run '^TestFindTilemapWindow$' ./...
@@ -148,3 +176,46 @@ func TestFindTilemapWindow(t *testing.T) {
 		})
 	}
 }
+
+// flipTile returns the global tile ID gid with the flip flags set.
+func flipTile(gid int, flags uint32) int {
+	return int(uint32(gid) | flags)
+}
+
+type tileFlipTest struct {
+	name    string
+	flags   uint32
+	x, y    float64
+	expectX float64
+	expectY float64
+}
+
+// tile is 16x8
+var tileFlipTestTable = []tileFlipTest{
+	{name: "no flip", flags: 0, x: 1, y: 0, expectX: 1, expectY: 0},
+	{name: "horizontal", flags: tileFlipHorizontal, x: 1, y: 0, expectX: 15, expectY: 0},
+	{name: "vertical", flags: tileFlipVertical, x: 0, y: 1, expectX: 0, expectY: 7},
+	{name: "diagonal", flags: tileFlipDiagonal, x: 1, y: 0, expectX: 0, expectY: 1},
+	{name: "rotate 90 clockwise", flags: tileFlipDiagonal | tileFlipHorizontal, x: 0, y: 0, expectX: 8, expectY: 0},
+	{name: "rotate 180", flags: tileFlipHorizontal | tileFlipVertical, x: 1, y: 0, expectX: 15, expectY: 8},
+	{name: "rotate 90 counter-clockwise", flags: tileFlipDiagonal | tileFlipVertical, x: 0, y: 0, expectX: 0, expectY: 16},
+}
+
+// go test -count 1 -run '^TestTileFlipGeoM$' ./...
+func TestTileFlipGeoM(t *testing.T) {
+	const gid = 5
+	for i, data := range tileFlipTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(tileFlipTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			var geom ebiten.GeoM
+			tileFlipGeoM(&geom, flipTile(gid, data.flags), 16, 8)
+			x, y := geom.Apply(data.x, data.y)
+			if x != data.expectX || y != data.expectY {
+				t.Errorf("wrong point: expected %vx%v got %vx%v", data.expectX, data.expectY, x, y)
+			}
+			if got := flipTile(gid, data.flags) & tileGIDMask; got != gid {
+				t.Errorf("wrong gid: expected %d got %d", gid, got)
+			}
+		})
+	}
+}
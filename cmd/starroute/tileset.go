@@ -0,0 +1,162 @@
+package main
+
+import (
+	"image"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// tileset is a sprite sheet of tiles.
+// Layer values from firstGID up to firstGID+tileCount-1 are global tile IDs
+// that refer to the tiles of this tileset.
+type tileset struct {
+	name                  string
+	image                 *ebiten.Image
+	firstGID              int
+	tileWidth, tileHeight int
+	tileCount, columns    int
+	margin, spacing       int
+	animations            map[int]*tileAnimation // key is local tile index
+	properties            map[int]tileProps      // key is local tile index
+}
+
+// tileAnimation cycles a tile through a sequence of frames.
+type tileAnimation struct {
+	frames []tileFrame
+	total  time.Duration
+}
+
+// tileFrame shows the tile index for the duration.
+type tileFrame struct {
+	index    int
+	duration time.Duration
+}
+
+// frameAt returns the tile index shown at the animation clock.
+func (a *tileAnimation) frameAt(clock time.Duration) int {
+	if a.total <= 0 {
+		return a.frames[0].index
+	}
+	t := clock % a.total
+	for _, f := range a.frames {
+		if t < f.duration {
+			return f.index
+		}
+		t -= f.duration
+	}
+	return a.frames[len(a.frames)-1].index
+}
+
+// newTileset creates a tileset from a sprite sheet.
+// If columns or tileCount are zero, they are computed from the image size.
+func newTileset(name string, img *ebiten.Image, firstGID, tileWidth, tileHeight,
+	margin, spacing, columns, tileCount int) *tileset {
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	if columns == 0 {
+		columns = (w - 2*margin + spacing) / (tileWidth + spacing)
+	}
+	if tileCount == 0 {
+		rows := (h - 2*margin + spacing) / (tileHeight + spacing)
+		tileCount = columns * rows
+	}
+
+	set := &tileset{
+		name:       name,
+		image:      img,
+		firstGID:   firstGID,
+		tileWidth:  tileWidth,
+		tileHeight: tileHeight,
+		tileCount:  tileCount,
+		columns:    columns,
+		margin:     margin,
+		spacing:    spacing,
+	}
+
+	log.Printf("Tileset %q: image %dx%d, tile %dx%d, %d tiles in %d columns, firstGID %d",
+		name, w, h, tileWidth, tileHeight, tileCount, columns, firstGID)
+
+	return set
+}
+
+// tileRect returns the location of the tile index within the tileset image.
+func (set *tileset) tileRect(index int) image.Rectangle {
+	col := index % set.columns
+	row := index / set.columns
+	x := set.margin + col*(set.tileWidth+set.spacing)
+	y := set.margin + row*(set.tileHeight+set.spacing)
+	return image.Rect(x, y, x+set.tileWidth, y+set.tileHeight)
+}
+
+// addAnimation makes the tile index cycle through frames.
+func (set *tileset) addAnimation(index int, frames []tileFrame) {
+	if len(frames) == 0 {
+		return
+	}
+	a := &tileAnimation{frames: frames}
+	for _, f := range frames {
+		a.total += f.duration
+	}
+	if set.animations == nil {
+		set.animations = map[int]*tileAnimation{}
+	}
+	set.animations[index] = a
+}
+
+// setProps sets the gameplay properties of the tile index.
+func (set *tileset) setProps(index int, props tileProps) {
+	if set.properties == nil {
+		set.properties = map[int]tileProps{}
+	}
+	set.properties[index] = props
+}
+
+// animatedTile returns the tile index to draw for the tile index at the animation clock.
+// Tiles without animation are returned unchanged.
+func (set *tileset) animatedTile(index int, clock time.Duration) int {
+	if a, found := set.animations[index]; found {
+		return a.frameAt(clock)
+	}
+	return index
+}
+
+// tileImage returns the image for the tile index.
+func (set *tileset) tileImage(index int) *ebiten.Image {
+	return set.image.SubImage(set.tileRect(index)).(*ebiten.Image)
+}
+
+// tilesetRegistry resolves global tile IDs into tiles of several tilesets.
+// Tilesets are kept sorted by firstGID.
+type tilesetRegistry struct {
+	tilesets []*tileset
+}
+
+// add registers a tileset.
+func (reg *tilesetRegistry) add(set *tileset) {
+	reg.tilesets = append(reg.tilesets, set)
+	sort.Slice(reg.tilesets, func(i, j int) bool {
+		return reg.tilesets[i].firstGID < reg.tilesets[j].firstGID
+	})
+}
+
+// resolve finds the tileset and the local tile index for a global tile ID.
+// It returns a nil tileset if the global tile ID does not belong to any tileset.
+func (reg *tilesetRegistry) resolve(gid int) (*tileset, int) {
+	// find the last tileset with firstGID <= gid
+	i := sort.Search(len(reg.tilesets), func(i int) bool {
+		return reg.tilesets[i].firstGID > gid
+	}) - 1
+	if i < 0 {
+		return nil, 0
+	}
+	set := reg.tilesets[i]
+	index := gid - set.firstGID
+	if index >= set.tileCount {
+		return nil, 0
+	}
+	return set, index
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"testing"
+	"time"
+)
+
+func testTilesetRegistry() *tilesetRegistry {
+	var reg tilesetRegistry
+	// added out of order on purpose
+	reg.add(&tileset{name: "station", firstGID: 101, tileWidth: 32, tileHeight: 32,
+		tileCount: 20, columns: 5, margin: 1, spacing: 2})
+	reg.add(&tileset{name: "terrain", firstGID: 1, tileWidth: 16, tileHeight: 16,
+		tileCount: 100, columns: 10})
+	return &reg
+}
+
+type resolveTest struct {
+	name        string
+	gid         int
+	expectSet   string
+	expectIndex int
+	expectRect  image.Rectangle
+}
+
+var resolveTestTable = []resolveTest{
+	{
+		name:      "empty",
+		gid:       0,
+		expectSet: "",
+	},
+	{
+		name:        "first tile of first tileset",
+		gid:         1,
+		expectSet:   "terrain",
+		expectIndex: 0,
+		expectRect:  image.Rect(0, 0, 16, 16),
+	},
+	{
+		name:        "last tile of first tileset",
+		gid:         100,
+		expectSet:   "terrain",
+		expectIndex: 99,
+		expectRect:  image.Rect(144, 144, 160, 160),
+	},
+	{
+		name:        "first tile of second tileset with margin",
+		gid:         101,
+		expectSet:   "station",
+		expectIndex: 0,
+		expectRect:  image.Rect(1, 1, 33, 33),
+	},
+	{
+		name:        "second row of second tileset with spacing",
+		gid:         107,
+		expectSet:   "station",
+		expectIndex: 6,
+		expectRect:  image.Rect(35, 35, 67, 67),
+	},
+	{
+		name:      "beyond last tileset",
+		gid:       121,
+		expectSet: "",
+	},
+}
+
+// go test -count 1 -run '^TestTilesetResolve$' ./...
+func TestTilesetResolve(t *testing.T) {
+	reg := testTilesetRegistry()
+	for i, data := range resolveTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(resolveTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			set, index := reg.resolve(data.gid)
+			if set == nil {
+				if data.expectSet != "" {
+					t.Errorf("expected tileset %s, got none", data.expectSet)
+				}
+				return
+			}
+			if set.name != data.expectSet {
+				t.Errorf("wrong tileset: expected %s got %s", data.expectSet, set.name)
+			}
+			if index != data.expectIndex {
+				t.Errorf("wrong index: expected %d got %d", data.expectIndex, index)
+			}
+			if r := set.tileRect(index); r != data.expectRect {
+				t.Errorf("wrong rect: expected %v got %v", data.expectRect, r)
+			}
+		})
+	}
+}
+
+type tileAnimationTest struct {
+	name        string
+	clock       time.Duration
+	expectIndex int
+}
+
+// frames: 7 for 100ms, 8 for 300ms, 9 for 100ms
+var tileAnimationTestTable = []tileAnimationTest{
+	{name: "start", clock: 0, expectIndex: 7},
+	{name: "end of first frame", clock: 99 * time.Millisecond, expectIndex: 7},
+	{name: "second frame", clock: 100 * time.Millisecond, expectIndex: 8},
+	{name: "end of second frame", clock: 399 * time.Millisecond, expectIndex: 8},
+	{name: "last frame", clock: 450 * time.Millisecond, expectIndex: 9},
+	{name: "wraps around", clock: 650 * time.Millisecond, expectIndex: 8},
+}
+
+// go test -count 1 -run '^TestTileAnimation$' ./...
+func TestTileAnimation(t *testing.T) {
+	set := &tileset{}
+	set.addAnimation(3, []tileFrame{
+		{index: 7, duration: 100 * time.Millisecond},
+		{index: 8, duration: 300 * time.Millisecond},
+		{index: 9, duration: 100 * time.Millisecond},
+	})
+	if got := set.animatedTile(4, time.Second); got != 4 {
+		t.Errorf("tile without animation changed: got %d", got)
+	}
+	for i, data := range tileAnimationTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(tileAnimationTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := set.animatedTile(3, data.clock); got != data.expectIndex {
+				t.Errorf("wrong frame: expected %d got %d", data.expectIndex, got)
+			}
+		})
+	}
+}
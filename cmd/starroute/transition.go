@@ -0,0 +1,212 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// transition kinds
+const (
+	transitionCut       = iota // instant switch
+	transitionFade             // fade to black, then in from black
+	transitionCrossfade        // blend the scenes
+	transitionWipe             // an edge sweeps across, uncovering the new scene
+)
+
+// transitionStyle is how the game switches into a scene.
+type transitionStyle struct {
+	kind    int
+	seconds float64
+
+	// wipe direction: the edge between the scenes moves along dirX,dirY,
+	// one of them zero and the other 1 or -1
+	dirX, dirY int
+}
+
+// sceneTransitionDefault is the transition into scenes that set none.
+var sceneTransitionDefault = transitionStyle{kind: transitionFade, seconds: 0.6}
+
+// transitionKinds are the transition kinds by name, as in scene files.
+var transitionKinds = map[string]int{
+	"cut":       transitionCut,
+	"fade":      transitionFade,
+	"crossfade": transitionCrossfade,
+	"wipe":      transitionWipe,
+}
+
+// wipeDirections are the directions the wipe edge moves, by name.
+var wipeDirections = map[string]image.Point{
+	"right": {1, 0},
+	"left":  {-1, 0},
+	"down":  {0, 1},
+	"up":    {0, -1},
+}
+
+// transition is a running switch from one scene to another.
+// The input is locked until it ends, and the music of the scenes fades
+// in step with the visuals.
+type transition struct {
+	style    transitionStyle
+	from, to *scene
+	elapsed  float64
+
+	// offscreen images the scenes are rendered to, for blending
+	fromImage, toImage *ebiten.Image
+}
+
+// progress returns how far the transition is, from 0 to 1.
+func (tr *transition) progress() float64 {
+	if tr.style.seconds <= 0 {
+		return 1
+	}
+	return min(tr.elapsed/tr.style.seconds, 1)
+}
+
+// done reports whether the transition is over.
+func (tr *transition) done() bool {
+	return tr.progress() >= 1
+}
+
+// volumes returns the music volume of the scene switched from and of the
+// scene switched to. A fade goes through silence as it goes through
+// black, other transitions blend both tracks.
+func (tr *transition) volumes() (from, to float64) {
+	t := tr.progress()
+	if tr.style.kind == transitionFade {
+		return max(1-2*t, 0), max(2*t-1, 0)
+	}
+	return 1 - t, t
+}
+
+// wipeRect returns the screen rectangle of width x height pixels showing
+// the new scene during a wipe.
+func (tr *transition) wipeRect(width, height int) image.Rectangle {
+	t := easeInOutCubic(tr.progress())
+	w := int(t * float64(width))
+	h := int(t * float64(height))
+	switch {
+	case tr.style.dirX > 0:
+		return image.Rect(0, 0, w, height)
+	case tr.style.dirX < 0:
+		return image.Rect(width-w, 0, width, height)
+	case tr.style.dirY > 0:
+		return image.Rect(0, 0, width, h)
+	default:
+		return image.Rect(0, height-h, width, height)
+	}
+}
+
+// startTransition switches from the scene from to the scene to, which
+// is already the current scene. A transition still running ends at once.
+func (g *game) startTransition(from, to *scene) {
+	g.endTransition()
+
+	tr := &transition{style: to.transitionIn, from: from, to: to}
+	if from == to {
+		tr.style.kind = transitionCut
+	}
+	if tr.style.kind == transitionCut {
+		tr.style.seconds = 0
+	}
+	g.transition = tr
+
+	to.musicStart()
+	g.updateTransition(0)
+}
+
+// updateTransition advances the running transition, if any, by dt
+// seconds, ending it when it is over.
+func (g *game) updateTransition(dt float64) {
+	tr := g.transition
+	if tr == nil {
+		return
+	}
+	tr.elapsed += dt
+	fromVolume, toVolume := tr.volumes()
+	if tr.from != tr.to {
+		tr.from.musicVolume(fromVolume)
+	}
+	tr.to.musicVolume(toVolume)
+	if tr.done() {
+		g.endTransition()
+	}
+}
+
+// endTransition ends the running transition, if any, at once.
+func (g *game) endTransition() {
+	tr := g.transition
+	if tr == nil {
+		return
+	}
+	g.transition = nil
+	if tr.from != tr.to {
+		tr.from.musicStop()
+	}
+	tr.to.musicVolume(1)
+	for _, img := range []*ebiten.Image{tr.fromImage, tr.toImage} {
+		if img != nil {
+			img.Deallocate()
+		}
+	}
+	log.Printf("transition ended: %s", tr.to.name)
+}
+
+// drawTransition draws the running transition on the screen.
+func (g *game) drawTransition(screen *ebiten.Image, debug bool) {
+	tr := g.transition
+	t := tr.progress()
+
+	switch tr.style.kind {
+	case transitionFade:
+		// through black, each scene drawn directly
+		sc, alpha := tr.from, 2*t
+		if t >= 0.5 {
+			sc, alpha = tr.to, 2-2*t
+		}
+		sc.behavior.draw(sc, screen, debug)
+		b := screen.Bounds()
+		black := color.NRGBA{0, 0, 0, uint8(min(alpha, 1) * 255)}
+		vector.FillRect(screen, 0, 0, float32(b.Dx()), float32(b.Dy()), black, false)
+
+	case transitionCrossfade:
+		tr.renderScenes(screen.Bounds().Size(), debug)
+		screen.DrawImage(tr.fromImage, nil)
+		op := &ebiten.DrawImageOptions{}
+		op.ColorScale.ScaleAlpha(float32(t))
+		screen.DrawImage(tr.toImage, op)
+
+	case transitionWipe:
+		size := screen.Bounds().Size()
+		tr.renderScenes(size, debug)
+		screen.DrawImage(tr.fromImage, nil)
+		r := tr.wipeRect(size.X, size.Y)
+		if !r.Empty() {
+			// the sub-image clips the new scene to the wiped rectangle
+			screen.SubImage(r).(*ebiten.Image).DrawImage(tr.toImage, nil)
+		}
+
+	default:
+		tr.to.behavior.draw(tr.to, screen, debug)
+	}
+}
+
+// renderScenes renders both scenes into their offscreen images of the
+// given size.
+func (tr *transition) renderScenes(size image.Point, debug bool) {
+	render := func(img **ebiten.Image, sc *scene) {
+		if *img == nil || (*img).Bounds().Size() != size {
+			if *img != nil {
+				(*img).Deallocate()
+			}
+			*img = ebiten.NewImage(size.X, size.Y)
+		}
+		(*img).Fill(color.Black)
+		sc.behavior.draw(sc, *img, debug)
+	}
+	render(&tr.fromImage, tr.from)
+	render(&tr.toImage, tr.to)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+type transitionTest struct {
+	name       string
+	style      transitionStyle
+	elapsed    float64
+	expectFrom float64 // music volume
+	expectTo   float64
+	expectWipe image.Rectangle // of a 100x50 screen
+	expectDone bool
+}
+
+var transitionTestTable = []transitionTest{
+	{"fade start", transitionStyle{kind: transitionFade, seconds: 2}, 0, 1, 0, image.Rectangle{}, false},
+	{"fade quarter", transitionStyle{kind: transitionFade, seconds: 2}, 0.5, 0.5, 0, image.Rectangle{}, false},
+	{"fade black", transitionStyle{kind: transitionFade, seconds: 2}, 1, 0, 0, image.Rectangle{}, false},
+	{"fade three quarters", transitionStyle{kind: transitionFade, seconds: 2}, 1.5, 0, 0.5, image.Rectangle{}, false},
+	{"fade end", transitionStyle{kind: transitionFade, seconds: 2}, 2, 0, 1, image.Rectangle{}, true},
+	{"fade beyond end", transitionStyle{kind: transitionFade, seconds: 2}, 3, 0, 1, image.Rectangle{}, true},
+	{"crossfade quarter", transitionStyle{kind: transitionCrossfade, seconds: 2}, 0.5, 0.75, 0.25, image.Rectangle{}, false},
+	{"cut", transitionStyle{kind: transitionCut}, 0, 0, 1, image.Rectangle{}, true},
+	{"wipe right half", transitionStyle{kind: transitionWipe, seconds: 2, dirX: 1}, 1, 0.5, 0.5, image.Rect(0, 0, 50, 50), false},
+	{"wipe left half", transitionStyle{kind: transitionWipe, seconds: 2, dirX: -1}, 1, 0.5, 0.5, image.Rect(50, 0, 100, 50), false},
+	{"wipe down half", transitionStyle{kind: transitionWipe, seconds: 2, dirY: 1}, 1, 0.5, 0.5, image.Rect(0, 0, 100, 25), false},
+	{"wipe up half", transitionStyle{kind: transitionWipe, seconds: 2, dirY: -1}, 1, 0.5, 0.5, image.Rect(0, 25, 100, 50), false},
+	{"wipe right start", transitionStyle{kind: transitionWipe, seconds: 2, dirX: 1}, 0, 1, 0, image.Rect(0, 0, 0, 50), false},
+	{"wipe right end", transitionStyle{kind: transitionWipe, seconds: 2, dirX: 1}, 2, 0, 1, image.Rect(0, 0, 100, 50), true},
+}
+
+// go test -count 1 -run '^TestTransition$' ./...
+func TestTransition(t *testing.T) {
+	for i, data := range transitionTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(transitionTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			tr := &transition{style: data.style, elapsed: data.elapsed}
+			if from, to := tr.volumes(); from != data.expectFrom || to != data.expectTo {
+				t.Errorf("expected volumes %v,%v got %v,%v", data.expectFrom, data.expectTo, from, to)
+			}
+			if tr.done() != data.expectDone {
+				t.Errorf("expected done=%t", data.expectDone)
+			}
+			if data.style.kind != transitionWipe {
+				return
+			}
+			if r := tr.wipeRect(100, 50); r != data.expectWipe {
+				t.Errorf("expected wipe %v, got %v", data.expectWipe, r)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestSwitchScene$' ./...
+func TestSwitchScene(t *testing.T) {
+	g := &game{screenWidth: 800, screenHeight: 600}
+	for range 3 {
+		sc := newTestScene(10, false, sceneOptions{})
+		sc.g = g
+		sc.input = "play"
+		sc.behavior = &flightBehavior{}
+		sc.transitionIn = transitionStyle{kind: transitionCrossfade, seconds: 1}
+		g.scenes = append(g.scenes, sc)
+	}
+	g.scenes[2].transitionIn = transitionStyle{kind: transitionCut}
+	g.sceneCurrent = 0
+	g.stack = []*scene{g.scenes[0], g.scenes[1]} // an overlay on top
+
+	// leaving the overlay for the scene below it is a cut
+	g.switchScene(1)
+	if g.transition != nil || len(g.stack) != 1 || g.getCurrentScene() != g.scenes[1] {
+		t.Fatalf("expected cut from the overlay to scene 1: transition=%v stack=%d", g.transition, len(g.stack))
+	}
+
+	g.stack = []*scene{g.scenes[0]}
+	g.switchScene(1)
+	if g.transition == nil || g.transition.from != g.scenes[0] || g.transition.to != g.scenes[1] {
+		t.Fatalf("expected transition from scene 0 to scene 1: %v", g.transition)
+	}
+	if g.sceneCurrent != 1 || len(g.stack) != 1 || g.getCurrentScene() != g.scenes[1] {
+		t.Errorf("expected scene 1 alone on the stack: current=%d stack=%d", g.sceneCurrent, len(g.stack))
+	}
+	g.updateTransition(0.5)
+	if g.transition == nil {
+		t.Fatalf("transition ended too early")
+	}
+	g.updateTransition(0.5)
+	if g.transition != nil {
+		t.Fatalf("transition did not end: %v", g.transition)
+	}
+
+	// a cut ends at once
+	g.switchScene(2)
+	if g.transition != nil || g.sceneCurrent != 2 {
+		t.Errorf("expected cut to scene 2: transition=%v current=%d", g.transition, g.sceneCurrent)
+	}
+}
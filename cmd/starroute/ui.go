@@ -32,6 +32,15 @@ func (sc *scene) drawSimpleUI(screen *ebiten.Image) {
 			Source: g.mplusFaceSource,
 			Size:   16,
 		}, op)
+
+		// what the player is on, like a dock or a mineral deposit
+		if terrain := sc.terrain.String(); terrain != "" && sc.player != nil {
+			op.GeoM.Translate(0, 20)
+			text.Draw(screen, terrain, &text.GoTextFace{
+				Source: g.mplusFaceSource,
+				Size:   16,
+			}, op)
+		}
 	}
 
 	if sc.opt.banner != "" {
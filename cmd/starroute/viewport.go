@@ -0,0 +1,107 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// viewport is the rectangle of the screen a camera draws into, in
+// fractions of the screen size, so it follows screen size changes.
+type viewport struct {
+	x, y, width, height float64
+}
+
+var (
+	viewportFull  = viewport{0, 0, 1, 1}
+	viewportLeft  = viewport{0, 0, 0.5, 1}
+	viewportRight = viewport{0.5, 0, 0.5, 1}
+	viewportInset = viewport{0.72, 0.04, 0.25, 0.25} // top-right corner
+)
+
+// rect returns the viewport in pixels of a screen of the given size.
+func (v viewport) rect(screenWidth, screenHeight int) image.Rectangle {
+	w, h := float64(screenWidth), float64(screenHeight)
+	return image.Rect(int(v.x*w), int(v.y*h),
+		int((v.x+v.width)*w), int((v.y+v.height)*h))
+}
+
+// screenRect returns the rectangle of the screen the camera draws into.
+func (c *camera) screenRect() image.Rectangle {
+	return c.viewport.rect(c.sc.g.screenWidth, c.sc.g.screenHeight)
+}
+
+// scene view modes, cycled with the V key
+const (
+	viewSingle = iota // the main camera fills the screen
+	viewInset         // a zoomed out inset follows the player
+	viewSplit         // split-screen, the right half follows the player
+	viewModes
+)
+
+// setViewMode replaces the extra cameras of the scene by those of the
+// view mode. The main camera is kept, only its viewport changes.
+func (sc *scene) setViewMode(mode int) {
+	main := sc.cam
+	main.setViewport(viewportFull)
+	sc.cams = []*camera{main}
+
+	target := sc.player
+	if target == nil {
+		target = main.target
+	}
+
+	addCam := func(v viewport, zoom float64) {
+		cam := newCamera(sc, main.cyclic, false)
+		cam.viewport = v
+		cam.x, cam.y = main.x, main.y
+		r := cam.screenRect()
+		cam.setZoom(zoom, r.Min.X, r.Min.Y)
+		cam.setTarget(target)
+		sc.cams = append(sc.cams, cam)
+	}
+
+	switch mode {
+	case viewInset:
+		addCam(viewportInset, camInsetZoom)
+	case viewSplit:
+		main.setViewport(viewportLeft)
+		addCam(viewportRight, main.zoom)
+	}
+	sc.viewMode = mode
+}
+
+// camInsetZoom shows more of the world in the small inset.
+const camInsetZoom = 0.5
+
+// setViewport changes the viewport, keeping the zoom within its limits.
+func (c *camera) setViewport(v viewport) {
+	c.viewport = v
+	r := c.screenRect()
+	c.setZoom(c.zoom, r.Min.X, r.Min.Y)
+}
+
+// cameraAt returns the topmost camera drawing at the screen position
+// sx,sy, or the main camera if none.
+func (sc *scene) cameraAt(sx, sy float64) *camera {
+	p := image.Pt(int(sx), int(sy))
+	for i := len(sc.cams) - 1; i >= 0; i-- {
+		if p.In(sc.cams[i].screenRect()) {
+			return sc.cams[i]
+		}
+	}
+	return sc.cam
+}
+
+// drawFrame outlines the viewport of the camera, unless it fills the
+// screen.
+func (c *camera) drawFrame(screen *ebiten.Image) {
+	r := c.screenRect()
+	if r == screen.Bounds() {
+		return
+	}
+	gray := color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+	drawDebugRect(screen, float32(1+r.Min.X), float32(1+r.Min.Y),
+		float32(r.Max.X), float32(r.Max.Y), gray)
+}
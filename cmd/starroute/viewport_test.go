@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+type viewportRectTest struct {
+	name   string
+	v      viewport
+	expect image.Rectangle
+}
+
+var viewportRectTestTable = []viewportRectTest{
+	{name: "full", v: viewportFull, expect: image.Rect(0, 0, 800, 600)},
+	{name: "left", v: viewportLeft, expect: image.Rect(0, 0, 400, 600)},
+	{name: "right", v: viewportRight, expect: image.Rect(400, 0, 800, 600)},
+	{name: "inset", v: viewportInset, expect: image.Rect(576, 24, 776, 174)},
+}
+
+// go test -count 1 -run '^TestViewportRect$' ./...
+func TestViewportRect(t *testing.T) {
+	for i, data := range viewportRectTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(viewportRectTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := data.v.rect(800, 600); got != data.expect {
+				t.Errorf("wrong rectangle: expected %v got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+// go test -count 1 -run '^TestViewMode$' ./...
+func TestViewMode(t *testing.T) {
+	sc := newTestScene(80, false, sceneOptions{})
+	sc.cam.x, sc.cam.y = 100, 200
+
+	sc.setViewMode(viewSplit)
+	if len(sc.cams) != 2 || sc.cams[0] != sc.cam {
+		t.Fatalf("wrong cameras: %v", sc.cams)
+	}
+	right := sc.cams[1]
+
+	// each half converts screen positions through its own viewport
+	if x, y := sc.cam.screenToWorld(10, 20); x != 110 || y != 220 {
+		t.Errorf("wrong left world position: %v,%v", x, y)
+	}
+	if x, y := right.screenToWorld(410, 20); x != 110 || y != 220 {
+		t.Errorf("wrong right world position: %v,%v", x, y)
+	}
+	if x, y := right.worldToScreen(110, 220); x != 410 || y != 20 {
+		t.Errorf("wrong right screen position: %v,%v", x, y)
+	}
+	if cam := sc.cameraAt(410, 20); cam != right {
+		t.Errorf("wrong camera at the right half")
+	}
+	if cam := sc.cameraAt(10, 20); cam != sc.cam {
+		t.Errorf("wrong camera at the left half")
+	}
+
+	// the view of the half screen is narrower
+	if w, h := sc.cam.viewSize(); w != 400 || h != 600 {
+		t.Errorf("wrong view size: %dx%d", w, h)
+	}
+
+	sc.setViewMode(viewInset)
+	if len(sc.cams) != 2 || sc.cams[1].zoom != camInsetZoom {
+		t.Errorf("wrong inset camera")
+	}
+	if cam := sc.cameraAt(700, 100); cam != sc.cams[1] {
+		t.Errorf("wrong camera at the inset")
+	}
+	if cam := sc.cameraAt(10, 20); cam != sc.cam {
+		t.Errorf("wrong camera outside the inset")
+	}
+
+	sc.setViewMode(viewSingle)
+	if len(sc.cams) != 1 || sc.cam.screenRect() != image.Rect(0, 0, 800, 600) {
+		t.Errorf("wrong single camera")
+	}
+}
@@ -117,6 +117,12 @@ func (p *Player) Close() error {
 	return p.audioPlayer.Close()
 }
 
+// SetVolume sets the volume, from 0 for silence to 1 for full volume.
+func (p *Player) SetVolume(volume float64) {
+	p.volume128 = int(volume * 128)
+	p.audioPlayer.SetVolume(volume)
+}
+
 // Update updates the player.
 func (p *Player) Update() error {
 	select {
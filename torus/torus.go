@@ -0,0 +1,190 @@
+// Package torus implements the geometry of a toroidal world: a plane that
+// wraps around at its edges, so leaving it at one edge enters it at the
+// opposite one.
+package torus
+
+import "math"
+
+// Wrap returns a modulo size, always in [0,size).
+func Wrap(a, size float64) float64 {
+	m := math.Mod(a, size)
+	if m < 0 {
+		m += size
+	}
+	if m >= size {
+		// a tiny negative a rounds up to size
+		m = 0
+	}
+	return m
+}
+
+// WrapInt returns a modulo size, always in [0,size).
+func WrapInt(a, size int) int {
+	m := a % size
+	if m < 0 {
+		m += size
+	}
+	return m
+}
+
+// Delta returns the displacement equivalent to d along an axis that wraps
+// around every size units, with the smallest magnitude, in [-size/2,size/2].
+func Delta(d, size float64) float64 {
+	d = math.Mod(d, size)
+	if d > size/2 {
+		return d - size
+	}
+	if d < -size/2 {
+		return d + size
+	}
+	return d
+}
+
+// Torus is a world of Width x Height units wrapping around at its edges.
+// It does not wrap along an axis of zero size, so the zero Torus is a
+// flat plane, and code can handle wrapped and bounded worlds alike.
+type Torus struct {
+	Width, Height float64
+}
+
+// New returns the world of width x height units.
+func New(width, height float64) Torus {
+	return Torus{Width: width, Height: height}
+}
+
+func wrapAxis(a, size float64) float64 {
+	if size <= 0 {
+		return a
+	}
+	return Wrap(a, size)
+}
+
+func deltaAxis(d, size float64) float64 {
+	if size <= 0 {
+		return d
+	}
+	return Delta(d, size)
+}
+
+// Normalize returns the position x,y wrapped into the world, within
+// [0,Width)x[0,Height).
+func (t Torus) Normalize(x, y float64) (float64, float64) {
+	return wrapAxis(x, t.Width), wrapAxis(y, t.Height)
+}
+
+// Displacement returns the shortest vector from x1,y1 to x2,y2, which
+// may cross the edges.
+func (t Torus) Displacement(x1, y1, x2, y2 float64) (dx, dy float64) {
+	return deltaAxis(x2-x1, t.Width), deltaAxis(y2-y1, t.Height)
+}
+
+// Nearest returns the copy of the position x,y nearest to refX,refY,
+// among those repeated every Width x Height units. The copy may lie
+// outside the world.
+func (t Torus) Nearest(x, y, refX, refY float64) (float64, float64) {
+	dx, dy := t.Displacement(refX, refY, x, y)
+	return refX + dx, refY + dy
+}
+
+// Rect is a rectangle at X,Y of Width x Height units. In a world, it
+// may cross the edges, wrapping around.
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// Empty reports whether the rectangle has no area.
+func (r Rect) Empty() bool {
+	return r.Width <= 0 || r.Height <= 0
+}
+
+// NormalizeRect returns r moved to start within the world. A rectangle
+// larger than the world is shrunk to the world size, as it covers all
+// of it.
+func (t Torus) NormalizeRect(r Rect) Rect {
+	r.X, r.Y = t.Normalize(r.X, r.Y)
+	if t.Width > 0 {
+		r.Width = min(r.Width, t.Width)
+	}
+	if t.Height > 0 {
+		r.Height = min(r.Height, t.Height)
+	}
+	return r
+}
+
+// Part is the piece of a rectangle within the world after it wraps
+// around the edges.
+type Part struct {
+	Rect // in the world
+
+	// offset of the part from the rectangle origin
+	OffsetX, OffsetY float64
+}
+
+// Split cuts r at the world edges into the parts within the world, at
+// most four: top-left, top-right, bottom-left and bottom-right, skipping
+// those that are empty.
+func (t Torus) Split(r Rect) []Part {
+	r = t.NormalizeRect(r)
+	if r.Empty() {
+		return nil
+	}
+	xs := splitAxis(r.X, r.Width, t.Width)
+	ys := splitAxis(r.Y, r.Height, t.Height)
+	parts := make([]Part, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			parts = append(parts, Part{
+				Rect:    Rect{X: x.start, Y: y.start, Width: x.length, Height: y.length},
+				OffsetX: x.offset,
+				OffsetY: y.offset,
+			})
+		}
+	}
+	return parts
+}
+
+type span struct {
+	start, length, offset float64
+}
+
+// splitAxis cuts the span of length from start, within [0,size), where
+// it crosses size.
+func splitAxis(start, length, size float64) []span {
+	if size <= 0 || start+length <= size {
+		return []span{{start, length, 0}}
+	}
+	first := size - start
+	return []span{{start, first, 0}, {0, length - first, first}}
+}
+
+// Intersect returns the parts of the world covered by both a and b.
+func (t Torus) Intersect(a, b Rect) []Rect {
+	var rects []Rect
+	for _, pa := range t.Split(a) {
+		for _, pb := range t.Split(b) {
+			if r := intersect(pa.Rect, pb.Rect); !r.Empty() {
+				rects = append(rects, r)
+			}
+		}
+	}
+	return rects
+}
+
+// Contains reports whether r covers the position x,y.
+func (t Torus) Contains(r Rect, x, y float64) bool {
+	dx := x - r.X
+	dy := y - r.Y
+	if t.Width > 0 {
+		dx = Wrap(dx, t.Width)
+	}
+	if t.Height > 0 {
+		dy = Wrap(dy, t.Height)
+	}
+	return dx >= 0 && dx < r.Width && dy >= 0 && dy < r.Height
+}
+
+func intersect(a, b Rect) Rect {
+	x1, y1 := max(a.X, b.X), max(a.Y, b.Y)
+	x2, y2 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	return Rect{X: x1, Y: y1, Width: max(x2-x1, 0), Height: max(y2-y1, 0)}
+}
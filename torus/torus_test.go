@@ -0,0 +1,355 @@
+package torus
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+type wrapTest struct {
+	name   string
+	a      float64
+	size   float64
+	expect float64
+}
+
+var wrapTestTable = []wrapTest{
+	{"inside", 3, 10, 3},
+	{"zero", 0, 10, 0},
+	{"at size", 10, 10, 0},
+	{"beyond size", 23, 10, 3},
+	{"negative", -3, 10, 7},
+	{"negative beyond size", -23, 10, 7},
+	{"tiny negative", -1e-20, 10, 0},
+	{"fraction", 10.5, 10, 0.5},
+}
+
+// go test -count 1 -run '^TestWrap$' ./...
+func TestWrap(t *testing.T) {
+	for i, data := range wrapTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(wrapTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := Wrap(data.a, data.size); got != data.expect {
+				t.Errorf("expected %v, got %v", data.expect, got)
+			}
+			if data.a != float64(int(data.a)) {
+				return
+			}
+			if got := WrapInt(int(data.a), int(data.size)); got != int(data.expect) {
+				t.Errorf("int: expected %v, got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+type deltaTest struct {
+	name   string
+	d      float64
+	size   float64
+	expect float64
+}
+
+var deltaTestTable = []deltaTest{
+	{"short forward", 3, 10, 3},
+	{"short backward", -3, 10, -3},
+	{"long forward", 7, 10, -3},
+	{"long backward", -7, 10, 3},
+	{"half", 5, 10, 5},
+	{"minus half", -5, 10, -5},
+	{"laps", 23, 10, 3},
+	{"negative laps", -27, 10, 3},
+	{"zero", 0, 10, 0},
+}
+
+// go test -count 1 -run '^TestDelta$' ./...
+func TestDelta(t *testing.T) {
+	for i, data := range deltaTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(deltaTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := Delta(data.d, data.size); got != data.expect {
+				t.Errorf("expected %v, got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+type pointTest struct {
+	name           string
+	world          Torus
+	x1, y1, x2, y2 float64
+
+	expectDX, expectDY float64
+}
+
+var pointTestTable = []pointTest{
+	{"same point", New(100, 50), 10, 10, 10, 10, 0, 0},
+	{"inside", New(100, 50), 10, 10, 13, 14, 3, 4},
+	{"across right edge", New(100, 50), 98, 10, 1, 14, 3, 4},
+	{"across left edge", New(100, 50), 1, 14, 98, 10, -3, -4},
+	{"across corner", New(100, 50), 99, 49, 2, 3, 3, 4},
+	{"outside world", New(100, 50), -2, 10, 201, 14, 3, 4},
+	{"cylinder", New(100, 0), 98, 10, 1, 14, 3, 4},
+	{"cylinder far", New(100, 0), 98, 10, 1, 110, 3, 100},
+	{"flat", Torus{}, 98, 10, 1, 14, -97, 4},
+}
+
+// go test -count 1 -run '^TestDisplacement$' ./...
+func TestDisplacement(t *testing.T) {
+	for i, data := range pointTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(pointTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			dx, dy := data.world.Displacement(data.x1, data.y1, data.x2, data.y2)
+			if dx != data.expectDX || dy != data.expectDY {
+				t.Errorf("expected displacement %vx%v, got %vx%v",
+					data.expectDX, data.expectDY, dx, dy)
+			}
+			x, y := data.world.Nearest(data.x2, data.y2, data.x1, data.y1)
+			if x != data.x1+dx || y != data.y1+dy {
+				t.Errorf("expected nearest %vx%v, got %vx%v", data.x1+dx, data.y1+dy, x, y)
+			}
+		})
+	}
+}
+
+type normalizeTest struct {
+	name             string
+	world            Torus
+	x, y             float64
+	expectX, expectY float64
+}
+
+var normalizeTestTable = []normalizeTest{
+	{"inside", New(100, 50), 10, 20, 10, 20},
+	{"beyond", New(100, 50), 110, 70, 10, 20},
+	{"negative", New(100, 50), -90, -30, 10, 20},
+	{"cylinder", New(100, 0), -90, -30, 10, -30},
+	{"flat", Torus{}, -90, -30, -90, -30},
+}
+
+// go test -count 1 -run '^TestNormalize$' ./...
+func TestNormalize(t *testing.T) {
+	for i, data := range normalizeTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(normalizeTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			x, y := data.world.Normalize(data.x, data.y)
+			if x != data.expectX || y != data.expectY {
+				t.Errorf("expected %vx%v, got %vx%v", data.expectX, data.expectY, x, y)
+			}
+		})
+	}
+}
+
+type splitTest struct {
+	name   string
+	world  Torus
+	rect   Rect
+	expect []Part
+}
+
+var splitTestTable = []splitTest{
+	{
+		name:   "inside",
+		world:  New(100, 50),
+		rect:   Rect{10, 20, 30, 10},
+		expect: []Part{{Rect{10, 20, 30, 10}, 0, 0}},
+	},
+	{
+		name:   "touching corner",
+		world:  New(100, 50),
+		rect:   Rect{70, 40, 30, 10},
+		expect: []Part{{Rect{70, 40, 30, 10}, 0, 0}},
+	},
+	{
+		name:  "across right edge",
+		world: New(100, 50),
+		rect:  Rect{80, 20, 30, 10},
+		expect: []Part{
+			{Rect{80, 20, 20, 10}, 0, 0},
+			{Rect{0, 20, 10, 10}, 20, 0},
+		},
+	},
+	{
+		name:  "across bottom edge",
+		world: New(100, 50),
+		rect:  Rect{10, 45, 30, 10},
+		expect: []Part{
+			{Rect{10, 45, 30, 5}, 0, 0},
+			{Rect{10, 0, 30, 5}, 0, 5},
+		},
+	},
+	{
+		name:  "across corner",
+		world: New(100, 50),
+		rect:  Rect{80, 45, 30, 10},
+		expect: []Part{
+			{Rect{80, 45, 20, 5}, 0, 0},
+			{Rect{0, 45, 10, 5}, 20, 0},
+			{Rect{80, 0, 20, 5}, 0, 5},
+			{Rect{0, 0, 10, 5}, 20, 5},
+		},
+	},
+	{
+		name:  "outside world",
+		world: New(100, 50),
+		rect:  Rect{-20, 120, 30, 10},
+		expect: []Part{
+			{Rect{80, 20, 20, 10}, 0, 0},
+			{Rect{0, 20, 10, 10}, 20, 0},
+		},
+	},
+	{
+		name:  "larger than world",
+		world: New(100, 50),
+		rect:  Rect{50, 0, 300, 50},
+		expect: []Part{
+			{Rect{50, 0, 50, 50}, 0, 0},
+			{Rect{0, 0, 50, 50}, 50, 0},
+		},
+	},
+	{
+		name:   "flat",
+		world:  Torus{},
+		rect:   Rect{-20, 120, 30, 10},
+		expect: []Part{{Rect{-20, 120, 30, 10}, 0, 0}},
+	},
+	{
+		name:   "empty",
+		world:  New(100, 50),
+		rect:   Rect{80, 20, 30, 0},
+		expect: nil,
+	},
+}
+
+// go test -count 1 -run '^TestSplit$' ./...
+func TestSplit(t *testing.T) {
+	for i, data := range splitTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(splitTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := data.world.Split(data.rect); !slices.Equal(got, data.expect) {
+				t.Errorf("expected %v, got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+type intersectTest struct {
+	name   string
+	world  Torus
+	a, b   Rect
+	expect []Rect
+}
+
+var intersectTestTable = []intersectTest{
+	{
+		name:   "overlap inside",
+		world:  New(100, 50),
+		a:      Rect{10, 10, 20, 20},
+		b:      Rect{20, 15, 20, 20},
+		expect: []Rect{{20, 15, 10, 15}},
+	},
+	{
+		name:   "apart",
+		world:  New(100, 50),
+		a:      Rect{10, 10, 20, 20},
+		b:      Rect{50, 10, 20, 20},
+		expect: nil,
+	},
+	{
+		name:   "touching",
+		world:  New(100, 50),
+		a:      Rect{10, 10, 20, 20},
+		b:      Rect{30, 10, 20, 20},
+		expect: nil,
+	},
+	{
+		name:   "overlap across edge",
+		world:  New(100, 50),
+		a:      Rect{90, 10, 20, 20},
+		b:      Rect{5, 10, 20, 20},
+		expect: []Rect{{5, 10, 5, 20}},
+	},
+	{
+		name:  "overlap across edge, unwrapped",
+		world: New(100, 50),
+		a:     Rect{-10, 10, 20, 20},
+		b:     Rect{195, 10, 20, 20},
+		expect: []Rect{
+			{95, 10, 5, 20},
+			{0, 10, 10, 20},
+		},
+	},
+	{
+		name:  "overlap both sides of the edge",
+		world: New(100, 50),
+		a:     Rect{90, 10, 20, 20},
+		b:     Rect{95, 20, 20, 20},
+		expect: []Rect{
+			{95, 20, 5, 10},
+			{0, 20, 10, 10},
+		},
+	},
+	{
+		name:  "wide rectangles overlap at both ends",
+		world: New(100, 50),
+		a:     Rect{0, 0, 60, 10},
+		b:     Rect{50, 0, 60, 10},
+		expect: []Rect{
+			{50, 0, 10, 10},
+			{0, 0, 10, 10},
+		},
+	},
+	{
+		name:   "flat apart across edge",
+		world:  Torus{},
+		a:      Rect{90, 10, 20, 20},
+		b:      Rect{5, 10, 20, 20},
+		expect: nil,
+	},
+}
+
+// go test -count 1 -run '^TestIntersect$' ./...
+func TestIntersect(t *testing.T) {
+	for i, data := range intersectTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(intersectTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			got := data.world.Intersect(data.a, data.b)
+			if !slices.Equal(got, data.expect) {
+				t.Errorf("expected %v, got %v", data.expect, got)
+			}
+			if got := data.world.Intersect(data.b, data.a); !slices.Equal(got, data.expect) {
+				t.Errorf("expected reverse %v, got %v", data.expect, got)
+			}
+		})
+	}
+}
+
+type containsTest struct {
+	name   string
+	world  Torus
+	rect   Rect
+	x, y   float64
+	expect bool
+}
+
+var containsTestTable = []containsTest{
+	{"inside", New(100, 50), Rect{10, 10, 20, 20}, 15, 15, true},
+	{"top-left corner", New(100, 50), Rect{10, 10, 20, 20}, 10, 10, true},
+	{"bottom-right corner", New(100, 50), Rect{10, 10, 20, 20}, 30, 30, false},
+	{"outside", New(100, 50), Rect{10, 10, 20, 20}, 5, 15, false},
+	{"across edge", New(100, 50), Rect{90, 40, 20, 20}, 5, 5, true},
+	{"unwrapped point", New(100, 50), Rect{10, 10, 20, 20}, 115, -35, true},
+	{"flat across edge", Torus{}, Rect{90, 40, 20, 20}, 5, 5, false},
+	{"flat unwrapped", Torus{}, Rect{90, 40, 20, 20}, 105, 55, true},
+}
+
+// go test -count 1 -run '^TestContains$' ./...
+func TestContains(t *testing.T) {
+	for i, data := range containsTestTable {
+		name := fmt.Sprintf("%02d of %02d: %s", i+1, len(containsTestTable), data.name)
+		t.Run(name, func(t *testing.T) {
+			if got := data.world.Contains(data.rect, data.x, data.y); got != data.expect {
+				t.Errorf("expected %v, got %v", data.expect, got)
+			}
+		})
+	}
+}